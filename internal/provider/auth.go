@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// authSchema returns the block that sets the credentials of every attempt.
+func authSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "The credentials sent with every attempt. Exactly one of `basic`, `bearer` or `api_key` must be set. " +
+			"They take precedence over the same headers in `request_headers`.",
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"basic": {
+					Description:  "HTTP Basic authentication.",
+					Type:         schema.TypeList,
+					Optional:     true,
+					MaxItems:     1,
+					ExactlyOneOf: []string{"auth.0.basic", "auth.0.bearer", "auth.0.api_key"},
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"username": {
+								Description: "The user name.",
+								Type:        schema.TypeString,
+								Required:    true,
+							},
+
+							"password": {
+								Description: "The password.",
+								Type:        schema.TypeString,
+								Required:    true,
+								Sensitive:   true,
+							},
+						},
+					},
+				},
+
+				"bearer": {
+					Description:  "A bearer token sent in the `Authorization` header.",
+					Type:         schema.TypeList,
+					Optional:     true,
+					MaxItems:     1,
+					ExactlyOneOf: []string{"auth.0.basic", "auth.0.bearer", "auth.0.api_key"},
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"token": {
+								Description: "The token.",
+								Type:        schema.TypeString,
+								Required:    true,
+								Sensitive:   true,
+							},
+						},
+					},
+				},
+
+				"api_key": {
+					Description:  "An API key sent in a request header or a query parameter.",
+					Type:         schema.TypeList,
+					Optional:     true,
+					MaxItems:     1,
+					ExactlyOneOf: []string{"auth.0.basic", "auth.0.bearer", "auth.0.api_key"},
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"header": {
+								Description:  "The name of the request header the key is sent in, such as `X-API-Key`.",
+								Type:         schema.TypeString,
+								Optional:     true,
+								ExactlyOneOf: []string{"auth.0.api_key.0.header", "auth.0.api_key.0.query"},
+							},
+
+							"query": {
+								Description:  "The name of the query parameter the key is sent in, such as `api_key`.",
+								Type:         schema.TypeString,
+								Optional:     true,
+								ExactlyOneOf: []string{"auth.0.api_key.0.header", "auth.0.api_key.0.query"},
+							},
+
+							"value": {
+								Description: "The key.",
+								Type:        schema.TypeString,
+								Required:    true,
+								Sensitive:   true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// authenticator sets credentials on the request of an attempt. It is called
+// before every attempt, so that credentials that expire or that sign the
+// request can be renewed.
+type authenticator interface {
+	authenticate(request *http.Request) error
+}
+
+type basicAuth struct {
+	username, password string
+}
+
+func (a basicAuth) authenticate(request *http.Request) error {
+	request.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+type bearerAuth struct {
+	token string
+}
+
+func (a bearerAuth) authenticate(request *http.Request) error {
+	request.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+type apiKeyAuth struct {
+	header, query, value string
+}
+
+func (a apiKeyAuth) authenticate(request *http.Request) error {
+	if a.header != "" {
+		request.Header.Set(a.header, a.value)
+		return nil
+	}
+
+	query := request.URL.Query()
+	query.Set(a.query, a.value)
+	request.URL.RawQuery = query.Encode()
+
+	return nil
+}
+
+// expandAuth returns the authenticators of the auth block of d.
+func expandAuth(d *schema.ResourceData) []authenticator {
+	blocks := d.Get("auth").([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+
+	block := blocks[0].(map[string]interface{})
+
+	if v := firstBlock(block["basic"]); v != nil {
+		return []authenticator{basicAuth{username: v["username"].(string), password: v["password"].(string)}}
+	}
+
+	if v := firstBlock(block["bearer"]); v != nil {
+		return []authenticator{bearerAuth{token: v["token"].(string)}}
+	}
+
+	if v := firstBlock(block["api_key"]); v != nil {
+		return []authenticator{apiKeyAuth{header: v["header"].(string), query: v["query"].(string), value: v["value"].(string)}}
+	}
+
+	return nil
+}
+
+// firstBlock returns the attributes of a block of MaxItems 1, or nil when it
+// is not set.
+func firstBlock(raw interface{}) map[string]interface{} {
+	blocks, ok := raw.([]interface{})
+	if !ok || len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+
+	return blocks[0].(map[string]interface{})
+}
+
+// describeRequest describes request for the logs without leaking credentials:
+// query values and header values are left out.
+func describeRequest(request *http.Request) string {
+	names := make([]string, 0, len(request.Header))
+	for name := range request.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return fmt.Sprintf("%s %s headers=[%s]", request.Method, redactURL(request.URL), strings.Join(names, ", "))
+}
+
+// redactURL returns u with its password and query values redacted, as they
+// may hold credentials.
+func redactURL(u *url.URL) string {
+	redacted := *u
+
+	if query := u.Query(); len(query) > 0 {
+		for name, values := range query {
+			for i := range values {
+				values[i] = "xxxxx"
+			}
+			query[name] = values
+		}
+		redacted.RawQuery = query.Encode()
+	}
+
+	return redacted.Redacted()
+}
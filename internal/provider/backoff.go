@@ -0,0 +1,489 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// statusPatternRegexp matches the status patterns accepted by retry_on_status
+// and expected_status: an exact code (404), a class (5xx) or a range (500-504).
+var statusPatternRegexp = regexp.MustCompile(`^([1-5][0-9][0-9]|[1-5]xx|[1-5][0-9][0-9]-[1-5][0-9][0-9])$`)
+
+// backoffSchema returns the attributes shared by the data source and the
+// resource that control the exponential backoff retry loop.
+func backoffSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"initial_interval": {
+			Description: "The initial exponential backoff interval.",
+			Type:        schema.TypeInt,
+			Optional:    true,
+		},
+
+		"max_elapsed_time": {
+			Description: "The maximum time to wait for.",
+			Type:        schema.TypeInt,
+			Optional:    true,
+		},
+		"randomization_factor": {
+			Description: "Randomization factor for exponential backoff.",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+		"multiplier": {
+			Description: "Multiplier for exponential backoff.",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+		"max_interval": {
+			Description: "Maximum interval factor for exponential backoff.",
+			Type:        schema.TypeInt,
+			Optional:    true,
+		},
+
+		"retry_on_status": {
+			Description: "A list of response status codes that should be retried. Each entry is either an exact code " +
+				"(`404`), a class of codes (`5xx`) or an inclusive range (`500-504`).",
+			Type: schema.TypeList,
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validateStatusPattern,
+			},
+			Optional: true,
+		},
+
+		"success_condition": successConditionSchema(),
+
+		"expected_status": {
+			Description: "A set of response status codes that define a successful request, using the same syntax as " +
+				"`retry_on_status`. When set, any other status code is retried, or fails immediately if it is not " +
+				"listed in `retry_on_status` either.",
+			Type: schema.TypeSet,
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validateStatusPattern,
+			},
+			Optional: true,
+		},
+	}
+}
+
+// backoffOptions holds the settings of the retry loop run by makeExponentialBackoffRequest.
+type backoffOptions struct {
+	InitialInterval     int64
+	MaxElapsedTime      int64
+	MaxInterval         int64
+	RandomizationFactor string
+	Multiplier          string
+	RetryOnStatus       []string
+	ExpectedStatus      []string
+	SuccessConditions   []successCondition
+	OutputFile          string
+	ResumeDownload      bool
+	ExpectedSHA256      string
+	MaxResponseBytes    int64
+	Auth                []authenticator
+	Redirects           *redirectPolicy
+
+	// CredentialsID identifies the configuration of Auth, for the response
+	// cache.
+	CredentialsID string
+}
+
+func backoffOptionsFromResourceData(d *schema.ResourceData) backoffOptions {
+	return backoffOptions{
+		InitialInterval:     int64(d.Get("initial_interval").(int)),
+		MaxElapsedTime:      int64(d.Get("max_elapsed_time").(int)),
+		MaxInterval:         int64(d.Get("max_interval").(int)),
+		RandomizationFactor: d.Get("randomization_factor").(string),
+		Multiplier:          d.Get("multiplier").(string),
+		RetryOnStatus:       expandStringList(d.Get("retry_on_status").([]interface{})),
+		ExpectedStatus:      expandStringList(d.Get("expected_status").(*schema.Set).List()),
+		SuccessConditions:   expandSuccessConditions(d),
+		OutputFile:          d.Get("output_file").(string),
+		ResumeDownload:      d.Get("resume_download").(bool),
+		ExpectedSHA256:      d.Get("expected_sha256").(string),
+		MaxResponseBytes:    int64(d.Get("max_response_bytes").(int)),
+		Auth:                expandAuth(d),
+		Redirects:           expandRedirectPolicy(d),
+	}
+}
+
+func makeExponentialBackoffRequest(ctx context.Context, client *http.Client, request *http.Request, opts backoffOptions) (*http.Response, string, string) {
+	var randomization_factor, multiplier float64
+	var err error
+
+	randomization_factor = backoff.DefaultRandomizationFactor
+	multiplier = backoff.DefaultMultiplier
+
+	initialInterval := opts.InitialInterval
+	maxElapsedTime := opts.MaxElapsedTime
+	maxInterval := opts.MaxInterval
+
+	if initialInterval == 0 {
+		initialInterval = int64(backoff.DefaultInitialInterval / time.Millisecond)
+	}
+
+	if maxElapsedTime == 0 {
+		maxElapsedTime = int64(60)
+	}
+
+	if maxInterval == 0 {
+		maxInterval = int64(backoff.DefaultMaxInterval / time.Millisecond)
+	}
+
+	if len(opts.RandomizationFactor) > 0 {
+		randomization_factor, err = strconv.ParseFloat(opts.RandomizationFactor, 64)
+		if err != nil {
+			return nil, "error converting randomization_factor to float64", fmt.Sprintf("%s", err)
+		}
+	}
+
+	if len(opts.Multiplier) > 0 {
+		multiplier, err = strconv.ParseFloat(opts.Multiplier, 64)
+		if err != nil {
+			return nil, "error converting multiplier to float64", fmt.Sprintf("%s", err)
+		}
+	}
+
+	for i, c := range opts.SuccessConditions {
+		if err := c.validate(); err != nil {
+			return nil, "Invalid success_condition", fmt.Sprintf("success_condition %d: %s", i, err)
+		}
+	}
+
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = time.Duration(maxElapsedTime) * time.Second
+	b.InitialInterval = time.Duration(initialInterval) * time.Millisecond
+	b.RandomizationFactor = randomization_factor
+	b.Multiplier = multiplier
+	b.MaxInterval = time.Duration(maxInterval) * time.Millisecond
+	s, err := json.MarshalIndent(b, "", "   ")
+	tflog.Info(ctx, fmt.Sprintf("Backoff configuration :  %s", s))
+
+	rb := &retryAfterBackOff{ExponentialBackOff: b}
+
+	var dl *download
+	if opts.OutputFile != "" {
+		dl = &download{
+			path:           opts.OutputFile,
+			expectedSHA256: opts.ExpectedSHA256,
+			maxBytes:       opts.MaxResponseBytes,
+			resume:         opts.ResumeDownload,
+		}
+		defer dl.discard()
+	}
+
+	retries := 0
+	var response *http.Response
+	err = backoff.Retry(func() error {
+		attempt, err := cloneRequest(ctx, request)
+		if err != nil {
+			return backoff.Permanent(fmt.Errorf("error rewinding request body: %w", err))
+		}
+
+		if dl != nil {
+			dl.prepare(attempt)
+		}
+
+		// Credentials are set on every attempt, after the request headers,
+		// so that they can be renewed between attempts.
+		unauthenticated, unauthenticatedURL := attempt.Header.Clone(), attempt.URL.String()
+		for _, a := range opts.Auth {
+			if err := a.authenticate(attempt); err != nil {
+				var credErr *credentialsError
+				if errors.As(err, &credErr) {
+					return backoff.Permanent(err)
+				}
+				return fmt.Errorf("error authenticating request: %w", err)
+			}
+		}
+
+		authenticated := changedHeaders(unauthenticated, attempt.Header)
+		if len(opts.Auth) > 0 && opts.CredentialsID != "" {
+			attempt = attempt.WithContext(withCacheCredentials(attempt.Context(), &cacheCredentials{
+				identity:         opts.CredentialsID,
+				url:              unauthenticatedURL,
+				authenticatedURL: attempt.URL.String(),
+				headers:          authenticated,
+			}))
+		}
+
+		if retries > 0 {
+			attempt = attempt.WithContext(withRetry(attempt.Context()))
+		}
+
+		attemptClient := client
+		if opts.Redirects != nil {
+			// The client may be shared, so the redirect policy of the
+			// attempt is set on a copy of it.
+			redirecting := *client
+			redirecting.CheckRedirect = opts.Redirects.checkRedirect(authHeaders(authenticated), client.Jar != nil)
+			attemptClient = &redirecting
+		}
+
+		tflog.Info(ctx, fmt.Sprintf("\nCalling http.Do : [%s]\n", describeRequest(attempt)))
+		response, err = attemptClient.Do(attempt)
+		redactURLError(err, attempt.URL)
+		tflog.Info(ctx, fmt.Sprintf("\nNumber of retries %d\n", retries))
+		tflog.Info(ctx, fmt.Sprintf("\nError %v\n", err))
+		retries++
+		if err != nil {
+			var pinErr *pinMismatchError
+			var redirectErr *redirectLimitError
+			if errors.As(err, &pinErr) || errors.As(err, &redirectErr) {
+				return backoff.Permanent(err)
+			}
+			return err
+		}
+
+		if response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable {
+			if delay, ok := retryAfterDelay(response.Header, time.Now()); ok {
+				tflog.Info(ctx, fmt.Sprintf("\nServer asked to retry after %s\n", delay))
+				rb.delay = delay
+			}
+		}
+
+		resumed := false
+		if dl != nil {
+			if resumed, err = dl.checkResume(response); err != nil {
+				return err
+			}
+		}
+
+		if !resumed {
+			if err := checkResponseStatus(response, opts.RetryOnStatus, opts.ExpectedStatus); err != nil {
+				return err
+			}
+		}
+
+		// The body is read within the attempt so that a truncated body, a
+		// checksum mismatch or an unmet success condition is retried like
+		// any other failure.
+		if dl != nil {
+			return dl.save(response)
+		}
+
+		body, err := readBody(response, opts.MaxResponseBytes)
+		if err != nil {
+			err = fmt.Errorf("error reading response body: %w", err)
+			if errors.Is(err, errResponseTooLarge) {
+				return backoff.Permanent(err)
+			}
+			return err
+		}
+
+		sum := sha256.Sum256(body)
+		if err := checkSHA256(sum[:], opts.ExpectedSHA256); err != nil {
+			return err
+		}
+
+		if err := checkSuccessConditions(opts.SuccessConditions, body); err != nil {
+			tflog.Info(ctx, fmt.Sprintf("\n%s\n", err))
+			return err
+		}
+
+		return nil
+	}, backoff.WithContext(rb, ctx))
+
+	if err != nil {
+		var pinErr *pinMismatchError
+		if errors.As(err, &pinErr) {
+			return nil, "Certificate pin mismatch", fmt.Sprintf("The server certificate matches none of the configured pins: %s", err)
+		}
+		return nil, "Error making request", fmt.Sprintf("Error making request: %s", err)
+	}
+
+	return response, "", ""
+}
+
+// redactURLError redacts the URL of err, when it is a *url.Error, since it is
+// logged and returned in diagnostics, and may hold credentials such as an
+// API key sent as a query parameter. The URL is the one of the redirect that
+// failed, if any, or else the one of the attempt.
+func redactURLError(err error, attemptURL *url.URL) {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return
+	}
+
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		u = attemptURL
+	}
+	urlErr.URL = redactURL(u)
+}
+
+// retryAfterBackOff wraps the exponential backoff so that a delay requested
+// by the server through Retry-After or RateLimit-Reset is used in place of
+// the next exponential interval. The requested delay is still capped by
+// MaxInterval and by the time left before MaxElapsedTime.
+type retryAfterBackOff struct {
+	*backoff.ExponentialBackOff
+
+	// delay is the wait requested by the last response, if any.
+	delay time.Duration
+}
+
+func (b *retryAfterBackOff) NextBackOff() time.Duration {
+	next := b.ExponentialBackOff.NextBackOff()
+	delay := b.delay
+	b.delay = 0
+
+	if next == backoff.Stop || delay <= 0 {
+		return next
+	}
+
+	if b.MaxInterval > 0 && delay > b.MaxInterval {
+		delay = b.MaxInterval
+	}
+
+	if b.MaxElapsedTime > 0 {
+		remaining := b.MaxElapsedTime - b.GetElapsedTime()
+		if remaining <= 0 {
+			return backoff.Stop
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+	}
+
+	return delay
+}
+
+// retryAfterDelay returns the delay requested by the Retry-After header,
+// given either in seconds or as an HTTP-date, or failing that by the
+// RateLimit-Reset header, given in seconds.
+func retryAfterDelay(header http.Header, now time.Time) (time.Duration, bool) {
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if date, err := http.ParseTime(v); err == nil {
+			if date.Before(now) {
+				return 0, true
+			}
+			return date.Sub(now), true
+		}
+	}
+
+	if v := header.Get("RateLimit-Reset"); v != "" {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+// checkResponseStatus decides whether a response ends the retry loop. It
+// returns nil for a successful response, a retryable error for a status that
+// should be retried, and a permanent error otherwise. The body of a response
+// that is not successful is drained and closed so that the connection can be
+// reused by the next attempt.
+func checkResponseStatus(response *http.Response, retryOnStatus, expectedStatus []string) error {
+	if len(expectedStatus) > 0 {
+		if matchStatus(response.StatusCode, expectedStatus) {
+			return nil
+		}
+	} else if !matchStatus(response.StatusCode, retryOnStatus) {
+		return nil
+	}
+
+	drainBody(response)
+
+	err := fmt.Errorf("unexpected response status %s", response.Status)
+	if len(retryOnStatus) > 0 && !matchStatus(response.StatusCode, retryOnStatus) {
+		return backoff.Permanent(err)
+	}
+
+	return err
+}
+
+// readBody reads and closes the body of response, and replaces it with an
+// in-memory copy that callers can read once the retry loop is over. A body
+// larger than maxBytes, when it is set, is not read.
+func readBody(response *http.Response, maxBytes int64) ([]byte, error) {
+	if response.Body == nil {
+		return nil, nil
+	}
+
+	defer response.Body.Close()
+
+	if maxBytes > 0 && response.ContentLength > maxBytes {
+		return nil, errResponseTooLarge
+	}
+
+	body, err := io.ReadAll(limitBody(response.Body, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		return nil, errResponseTooLarge
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+func drainBody(response *http.Response) {
+	if response.Body == nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+}
+
+// matchStatus reports whether code matches any of the given status patterns.
+func matchStatus(code int, patterns []string) bool {
+	for _, p := range patterns {
+		switch {
+		case len(p) == 3 && p[1:] == "xx":
+			if code/100 == int(p[0]-'0') {
+				return true
+			}
+		case len(p) == 7 && p[3] == '-':
+			low, _ := strconv.Atoi(p[:3])
+			high, _ := strconv.Atoi(p[4:])
+			if code >= low && code <= high {
+				return true
+			}
+		default:
+			if strconv.Itoa(code) == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func validateStatusPattern(v interface{}, k string) ([]string, []error) {
+	value, ok := v.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if !statusPatternRegexp.MatchString(value) {
+		return nil, []error{fmt.Errorf("%s: %q is not a status code, a class such as 5xx or a range such as 500-504", k, value)}
+	}
+
+	return nil, nil
+}
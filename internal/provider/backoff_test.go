@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+func TestMatchStatus(t *testing.T) {
+	testCases := []struct {
+		code     int
+		patterns []string
+		expected bool
+	}{
+		{code: 200, patterns: nil, expected: false},
+		{code: 404, patterns: []string{"404"}, expected: true},
+		{code: 404, patterns: []string{"403"}, expected: false},
+		{code: 503, patterns: []string{"5xx"}, expected: true},
+		{code: 429, patterns: []string{"5xx"}, expected: false},
+		{code: 502, patterns: []string{"500-504"}, expected: true},
+		{code: 505, patterns: []string{"500-504"}, expected: false},
+		{code: 429, patterns: []string{"5xx", "429"}, expected: true},
+	}
+
+	for _, tc := range testCases {
+		if got := matchStatus(tc.code, tc.patterns); got != tc.expected {
+			t.Errorf("matchStatus(%d, %v) = %t, expected %t", tc.code, tc.patterns, got, tc.expected)
+		}
+	}
+}
+
+func TestCheckResponseStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		code           int
+		retryOnStatus  []string
+		expectedStatus []string
+		success        bool
+		permanent      bool
+	}{
+		{name: "no policy", code: 503, success: true},
+		{name: "retried status", code: 503, retryOnStatus: []string{"5xx"}},
+		{name: "not retried status", code: 404, retryOnStatus: []string{"5xx"}, success: true},
+		{name: "expected status", code: 204, expectedStatus: []string{"2xx"}, success: true},
+		{name: "unexpected status without retry list", code: 404, expectedStatus: []string{"200"}},
+		{name: "unexpected status in retry list", code: 404, retryOnStatus: []string{"404"}, expectedStatus: []string{"200"}},
+		{name: "unexpected status outside retry list", code: 400, retryOnStatus: []string{"404"}, expectedStatus: []string{"200"}, permanent: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response := &http.Response{StatusCode: tc.code, Status: http.StatusText(tc.code)}
+			err := checkResponseStatus(response, tc.retryOnStatus, tc.expectedStatus)
+
+			if tc.success {
+				if err != nil {
+					t.Fatalf("expected success, got %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected an error, got none")
+			}
+
+			if _, ok := err.(*backoff.PermanentError); ok != tc.permanent {
+				t.Errorf("expected permanent error to be %t, got %T", tc.permanent, err)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "no header", header: http.Header{}},
+		{name: "seconds", header: http.Header{"Retry-After": []string{"3"}}, expected: 3 * time.Second, ok: true},
+		{name: "http date", header: http.Header{"Retry-After": []string{"Wed, 01 Jun 2022 12:00:05 GMT"}}, expected: 5 * time.Second, ok: true},
+		{name: "http date in the past", header: http.Header{"Retry-After": []string{"Wed, 01 Jun 2022 11:00:00 GMT"}}, ok: true},
+		{name: "invalid", header: http.Header{"Retry-After": []string{"soon"}}},
+		{name: "ratelimit reset", header: http.Header{"Ratelimit-Reset": []string{"7"}}, expected: 7 * time.Second, ok: true},
+		{name: "retry after wins", header: http.Header{"Retry-After": []string{"2"}, "Ratelimit-Reset": []string{"7"}}, expected: 2 * time.Second, ok: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, ok := retryAfterDelay(tc.header, now)
+			if ok != tc.ok || delay != tc.expected {
+				t.Errorf("expected (%s, %t), got (%s, %t)", tc.expected, tc.ok, delay, ok)
+			}
+		})
+	}
+}
+
+func TestRetryAfterBackOff(t *testing.T) {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 10 * time.Millisecond
+	b.RandomizationFactor = 0
+	b.MaxInterval = 2 * time.Second
+	b.MaxElapsedTime = time.Minute
+	rb := &retryAfterBackOff{ExponentialBackOff: b}
+	rb.Reset()
+
+	if next := rb.NextBackOff(); next != 10*time.Millisecond {
+		t.Errorf("expected the exponential interval without a requested delay, got %s", next)
+	}
+
+	rb.delay = time.Second
+	if next := rb.NextBackOff(); next != time.Second {
+		t.Errorf("expected the requested delay, got %s", next)
+	}
+
+	rb.delay = time.Hour
+	if next := rb.NextBackOff(); next != 2*time.Second {
+		t.Errorf("expected the requested delay to be capped by max interval, got %s", next)
+	}
+
+	b.MaxInterval = 2 * time.Hour
+	b.MaxElapsedTime = 5 * time.Second
+	rb.delay = time.Hour
+	if next := rb.NextBackOff(); next > 5*time.Second {
+		t.Errorf("expected the requested delay to be capped by max elapsed time, got %s", next)
+	}
+}
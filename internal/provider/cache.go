@@ -0,0 +1,640 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// cacheSchema returns the provider block that enables the response cache.
+func cacheSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Caches the bodies of `GET` responses on disk along with their `ETag` and `Last-Modified` validators. " +
+			"A cached response is reused as long as it is fresh, and is otherwise revalidated with a conditional request " +
+			"whose `304 Not Modified` answer reuses the cached body.",
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"directory": {
+					Description: "The directory the cached responses are stored in. It is created if needed.",
+					Type:        schema.TypeString,
+					Required:    true,
+				},
+
+				"ttl": {
+					Description: "The time in seconds a cached response is used without revalidating it, unless the response " +
+						"sets `Cache-Control: max-age`. Defaults to 0, so that every cached response is revalidated.",
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+
+				"max_age": {
+					Description: "The time in seconds after which a cached response that was neither used nor revalidated is " +
+						"removed from `directory`. Defaults to 604800, a week. Set to 0 to keep cached responses forever.",
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      604800,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+		},
+	}
+}
+
+// responseCache stores response bodies in a directory, one file per entry.
+// Each file holds the JSON encoded cacheEntry on its first line, followed by
+// the body, so that an entry is replaced atomically by renaming a new file
+// over it.
+type responseCache struct {
+	directory string
+	ttl       time.Duration
+	maxAge    time.Duration
+	now       func() time.Time
+
+	// pruneOnce removes the stale entries of directory, once per run.
+	pruneOnce sync.Once
+}
+
+func expandResponseCache(d *schema.ResourceData) *responseCache {
+	blocks := d.Get("cache").([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+
+	block := blocks[0].(map[string]interface{})
+	return &responseCache{
+		directory: block["directory"].(string),
+		ttl:       time.Duration(block["ttl"].(int)) * time.Second,
+		maxAge:    time.Duration(block["max_age"].(int)) * time.Second,
+		now:       time.Now,
+	}
+}
+
+// cacheEntry is the metadata of a cached response.
+type cacheEntry struct {
+	Header   http.Header `json:"header"`
+	StoredAt time.Time   `json:"stored_at"`
+
+	// Vary holds the values of the request headers named by the Vary header
+	// of the response, which a request must have to use the entry.
+	Vary map[string]string `json:"vary,omitempty"`
+}
+
+// wrap returns next wrapped by the cache, or next itself when c is nil.
+func (c *responseCache) wrap(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+
+	return &cachingTransport{cache: c, next: next}
+}
+
+// cachingTransport answers requests from the response cache, revalidating
+// stale entries with conditional requests.
+type cachingTransport struct {
+	cache *responseCache
+	next  http.RoundTripper
+}
+
+func (t *cachingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	c := t.cache
+
+	if !isCacheableRequest(request) {
+		response, err := t.next.RoundTrip(request)
+		if err == nil && isUnsafeMethod(request.Method) && response.StatusCode < 400 {
+			c.invalidate(request, response)
+		}
+		return response, err
+	}
+
+	c.pruneOnce.Do(c.prune)
+	path := c.path(request)
+
+	// A retry is made because the previous attempt did not get the expected
+	// response, so it must not get the same stored response again.
+	entry, body := c.load(path, request)
+	if entry != nil && !isRetry(request.Context()) && c.isFresh(entry) {
+		tflog.Info(request.Context(), fmt.Sprintf("Using the cached response of %s", redactURL(request.URL)))
+		c.touch(path)
+		return entry.response(request, body)
+	}
+
+	conditional := request
+	if entry != nil {
+		conditional = request.Clone(request.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" && request.Header.Get("If-None-Match") == "" {
+			conditional.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" && request.Header.Get("If-Modified-Since") == "" {
+			conditional.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	response, err := t.next.RoundTrip(conditional)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+
+	if entry != nil && response.StatusCode == http.StatusNotModified && conditional != request {
+		drainBody(response)
+		tflog.Info(request.Context(), fmt.Sprintf("Revalidated the cached response of %s", redactURL(request.URL)))
+
+		// The headers of a 304 response update the stored ones.
+		for name, values := range response.Header {
+			entry.Header[name] = values
+		}
+		entry.StoredAt = c.now()
+
+		if !isStorableResponse(request, entry.Header) {
+			// The open body can still be read once the entry is removed.
+			_ = os.Remove(path)
+			return entry.response(request, body)
+		}
+
+		refreshed, err := c.refresh(path, entry, body)
+		if err != nil {
+			// The body was consumed by the failed refresh, so drop the
+			// entry and make the request again without validators.
+			tflog.Warn(request.Context(), fmt.Sprintf("Error refreshing the cached response of %s: %s", redactURL(request.URL), err))
+			_ = os.Remove(path)
+			return t.next.RoundTrip(request)
+		}
+
+		return entry.response(request, refreshed)
+	}
+
+	if body != nil {
+		body.Close()
+	}
+
+	if response.StatusCode == http.StatusOK && isStorableResponse(request, response.Header) {
+		c.store(path, request, response)
+	} else if hasCacheDirective(response.Header, "no-store") {
+		_ = os.Remove(path)
+	}
+
+	return response, nil
+}
+
+// isCacheableRequest reports whether the response to request may come from
+// or be stored in the cache. Range requests are left alone, as the cache only
+// holds whole bodies.
+func isCacheableRequest(request *http.Request) bool {
+	return request.Method == http.MethodGet &&
+		request.Header.Get("Range") == "" &&
+		!hasCacheDirective(request.Header, "no-store")
+}
+
+// isUnsafeMethod reports whether method may change the state of the server,
+// which invalidates the stored responses of the resources it changes.
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+
+	return true
+}
+
+// invalidate removes the entries of the URL of request, and of the URLs of
+// the Location and Content-Location headers of response, after a successful
+// unsafe request.
+func (c *responseCache) invalidate(request *http.Request, response *http.Response) {
+	urls := []string{cacheURL(request)}
+	for _, name := range []string{"Location", "Content-Location"} {
+		if v := response.Header.Get(name); v != "" {
+			if u, err := request.URL.Parse(v); err == nil {
+				urls = append(urls, u.String())
+			}
+		}
+	}
+
+	for _, u := range urls {
+		paths, _ := filepath.Glob(filepath.Join(c.directory, urlHash(u)+"-*"))
+		for _, path := range paths {
+			if !strings.HasSuffix(path, ".tmp") {
+				_ = os.Remove(path)
+			}
+		}
+	}
+}
+
+// isStorableResponse reports whether a response with the given header may be
+// stored in reply to request.
+func isStorableResponse(request *http.Request, header http.Header) bool {
+	if hasCacheDirective(header, "no-store") || hasCacheDirective(request.Header, "no-store") {
+		return false
+	}
+
+	for _, name := range varyHeaders(header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cacheCredentials describes the credentials set on a request by the
+// authenticators. Tokens and signatures change on every attempt, so the
+// entry key holds the identity of the credentials in their place.
+type cacheCredentials struct {
+	// identity identifies the configuration of the credentials.
+	identity string
+
+	// url is the URL of the request before the authenticators, which may
+	// add query parameters to it, and authenticatedURL is the URL after.
+	url              string
+	authenticatedURL string
+
+	// headers are the names of the headers set by the authenticators.
+	headers []string
+}
+
+type cacheCredentialsKey struct{}
+
+func withCacheCredentials(ctx context.Context, credentials *cacheCredentials) context.Context {
+	return context.WithValue(ctx, cacheCredentialsKey{}, credentials)
+}
+
+type retryKey struct{}
+
+// withRetry marks the requests of ctx as retries of a failed attempt.
+func withRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+// cacheURL returns the URL of request before the authenticators, which may
+// add query parameters to it.
+func cacheURL(request *http.Request) string {
+	rawURL := request.URL.String()
+	if credentials, ok := request.Context().Value(cacheCredentialsKey{}).(*cacheCredentials); ok && rawURL == credentials.authenticatedURL {
+		return credentials.url
+	}
+
+	return rawURL
+}
+
+func urlHash(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns the file of the entry of request. Requests only share an entry
+// when they have the same URL, headers and credentials, so that responses are
+// never shared across credentials, whichever header carries them. The file
+// is named after the URL, then the whole key, so that the entries of a URL
+// can be invalidated together.
+func (c *responseCache) path(request *http.Request) string {
+	rawURL := cacheURL(request)
+	skip := map[string]bool{"If-None-Match": true, "If-Modified-Since": true, "Cache-Control": true}
+	identity := ""
+
+	if credentials, ok := request.Context().Value(cacheCredentialsKey{}).(*cacheCredentials); ok {
+		for _, name := range credentials.headers {
+			skip[name] = true
+		}
+		identity = credentials.identity
+	}
+
+	names := make([]string, 0, len(request.Header))
+	for name := range request.Header {
+		if !skip[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	key := sha256.New()
+	fmt.Fprintf(key, "%s %s\n", request.Method, rawURL)
+	for _, name := range names {
+		fmt.Fprintf(key, "%s: %q\n", name, request.Header[name])
+	}
+	if identity != "" {
+		fmt.Fprintf(key, "credentials: %s\n", identity)
+	}
+
+	return filepath.Join(c.directory, urlHash(rawURL)+"-"+hex.EncodeToString(key.Sum(nil)))
+}
+
+// cacheEntryRegexp matches the names of the entries and of their temporary
+// files, so that pruning leaves the other files of directory alone. Entries
+// stored before they were named after their URL only have the key hash.
+var cacheEntryRegexp = regexp.MustCompile(`^([0-9a-f]{64}-)?[0-9a-f]{64}(\.[0-9]+\.tmp)?$`)
+
+// prune removes the entries that were neither used nor revalidated for
+// maxAge, and the temporary files left behind by interrupted runs.
+func (c *responseCache) prune() {
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		return
+	}
+
+	now := c.now()
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !cacheEntryRegexp.MatchString(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		maxAge := c.maxAge
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			maxAge = time.Hour
+		}
+
+		if maxAge > 0 && now.Sub(info.ModTime()) > maxAge {
+			_ = os.Remove(filepath.Join(c.directory, entry.Name()))
+		}
+	}
+}
+
+// touch records that the entry in path was used, so that it is not pruned.
+func (c *responseCache) touch(path string) {
+	now := c.now()
+	_ = os.Chtimes(path, now, now)
+}
+
+// load returns the entry stored in path along with its body, positioned at
+// the start of the body, when it exists and its Vary headers match request.
+func (c *responseCache) load(path string, request *http.Request) (*cacheEntry, *os.File) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		file.Close()
+		return nil, nil
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(line, entry); err != nil || entry.Header == nil {
+		file.Close()
+		return nil, nil
+	}
+
+	for name, value := range entry.Vary {
+		if request.Header.Get(name) != value {
+			file.Close()
+			return nil, nil
+		}
+	}
+
+	if _, err := file.Seek(int64(len(line)), io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil
+	}
+
+	return entry, file
+}
+
+// isFresh reports whether entry can be used without revalidating it.
+func (c *responseCache) isFresh(entry *cacheEntry) bool {
+	lifetime := c.ttl
+	if hasCacheDirective(entry.Header, "no-cache") {
+		lifetime = 0
+	} else if maxAge, ok := cacheDirective(entry.Header, "max-age"); ok {
+		if seconds, err := strconv.ParseInt(maxAge, 10, 64); err == nil {
+			lifetime = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return lifetime > 0 && c.now().Sub(entry.StoredAt) < lifetime
+}
+
+// create opens a temporary file next to path and writes entry to it.
+func (c *responseCache) create(path string, entry *cacheEntry) (*os.File, error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(c.directory, 0o700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(c.directory, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// refresh replaces the entry in path with entry, keeping the body read from
+// body, and returns the new body.
+func (c *responseCache) refresh(path string, entry *cacheEntry, body io.ReadCloser) (*os.File, error) {
+	defer body.Close()
+
+	file, err := c.create(path, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = io.Copy(file, body)
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	refreshed, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := refreshed.Seek(offset, io.SeekStart); err != nil {
+		refreshed.Close()
+		return nil, err
+	}
+
+	return refreshed, nil
+}
+
+// store arranges for the body of response to be saved in path as it is read.
+// Caching is best effort: the response is left as is when it cannot be saved.
+func (c *responseCache) store(path string, request *http.Request, response *http.Response) {
+	entry := &cacheEntry{Header: response.Header.Clone(), StoredAt: c.now()}
+	for _, name := range varyHeaders(response.Header) {
+		if entry.Vary == nil {
+			entry.Vary = map[string]string{}
+		}
+		entry.Vary[name] = request.Header.Get(name)
+	}
+
+	file, err := c.create(path, entry)
+	if err != nil {
+		tflog.Warn(request.Context(), fmt.Sprintf("Error caching the response of %s: %s", redactURL(request.URL), err))
+		return
+	}
+
+	response.Body = &cachingBody{ReadCloser: response.Body, file: file, path: path}
+}
+
+// cachingBody copies a response body to a temporary file as it is read, and
+// renames the file to the entry path once the whole body has been read.
+type cachingBody struct {
+	io.ReadCloser
+	file *os.File
+	path string
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if b.file != nil {
+		if n > 0 {
+			if _, werr := b.file.Write(p[:n]); werr != nil {
+				b.abort()
+			}
+		}
+
+		if err == io.EOF {
+			b.commit()
+		} else if err != nil {
+			b.abort()
+		}
+	}
+
+	return n, err
+}
+
+func (b *cachingBody) Close() error {
+	// A body that was not read to the end is not cached.
+	b.abort()
+	return b.ReadCloser.Close()
+}
+
+func (b *cachingBody) commit() {
+	file := b.file
+	b.file = nil
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return
+	}
+
+	if err := os.Rename(file.Name(), b.path); err != nil {
+		os.Remove(file.Name())
+	}
+}
+
+func (b *cachingBody) abort() {
+	if b.file == nil {
+		return
+	}
+
+	b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+}
+
+// response builds the response to request from entry and its body.
+func (entry *cacheEntry) response(request *http.Request, body *os.File) (*http.Response, error) {
+	info, err := body.Stat()
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	offset, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Header.Clone(),
+		Body:          body,
+		ContentLength: info.Size() - offset,
+		Request:       request,
+	}, nil
+}
+
+// cacheDirective returns the value of a Cache-Control directive of header.
+func cacheDirective(header http.Header, name string) (string, bool) {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			key, arg, _ := strings.Cut(directive, "=")
+			if strings.EqualFold(strings.TrimSpace(key), name) {
+				return strings.Trim(strings.TrimSpace(arg), `"`), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func hasCacheDirective(header http.Header, name string) bool {
+	_, ok := cacheDirective(header, name)
+	return ok
+}
+
+// varyHeaders returns the canonical names of the headers listed in the Vary
+// header, or "*".
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,481 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// cacheTestServer counts the requests it gets and records their
+// conditional headers.
+type cacheTestServer struct {
+	handler func(w http.ResponseWriter, r *http.Request)
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (s *cacheTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	s.mu.Unlock()
+
+	s.handler(w, r)
+}
+
+func (s *cacheTestServer) last() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[len(s.requests)-1]
+}
+
+func (s *cacheTestServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.requests)
+}
+
+// testCache returns a cache in a temporary directory whose clock is moved by
+// advancing the returned time.
+func testCache(t *testing.T, ttl time.Duration) (*responseCache, *time.Time) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	return &responseCache{directory: t.TempDir(), ttl: ttl, now: func() time.Time { return now }}, &now
+}
+
+func testCachedGet(t *testing.T, c *responseCache, url string, header http.Header) (int, string) {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	client := &http.Client{Transport: c.wrap(http.DefaultTransport)}
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, string(body)
+}
+
+func TestResponseCache_Revalidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		validator   string
+		value       string
+		conditional string
+	}{
+		{name: "etag", validator: "ETag", value: `"v1"`, conditional: "If-None-Match"},
+		{name: "last-modified", validator: "Last-Modified", value: "Wed, 01 Jun 2022 09:00:00 GMT", conditional: "If-Modified-Since"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(tc.validator, tc.value)
+				if r.Header.Get(tc.conditional) == tc.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				_, _ = w.Write([]byte("discovery document"))
+			}}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			c, _ := testCache(t, 0)
+
+			for i := 0; i < 3; i++ {
+				status, body := testCachedGet(t, c, ts.URL, nil)
+				if status != http.StatusOK || body != "discovery document" {
+					t.Fatalf("request %d: expected the document, got %d %q", i, status, body)
+				}
+
+				if got := server.last().Header.Get(tc.conditional); i > 0 && got != tc.value {
+					t.Errorf("request %d: expected %s %s, got %q", i, tc.conditional, tc.value, got)
+				}
+			}
+
+			if server.count() != 3 {
+				t.Errorf("expected every request to be revalidated with a ttl of 0, got %d requests", server.count())
+			}
+		})
+	}
+}
+
+func TestResponseCache_Freshness(t *testing.T) {
+	testCases := []struct {
+		name         string
+		ttl          time.Duration
+		cacheControl string
+		fresh        time.Duration
+	}{
+		{name: "ttl", ttl: time.Minute, fresh: time.Minute},
+		{name: "max-age", ttl: time.Minute, cacheControl: "public, max-age=300", fresh: 5 * time.Minute},
+		{name: "no-cache", ttl: time.Minute, cacheControl: "no-cache", fresh: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+				if tc.cacheControl != "" {
+					w.Header().Set("Cache-Control", tc.cacheControl)
+				}
+				_, _ = w.Write([]byte("document"))
+			}}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+
+			c, now := testCache(t, tc.ttl)
+
+			testCachedGet(t, c, ts.URL, nil)
+
+			*now = now.Add(tc.fresh - time.Second)
+			if _, body := testCachedGet(t, c, ts.URL, nil); body != "document" {
+				t.Errorf("expected the cached body, got %q", body)
+			}
+
+			expected := 1
+			if tc.fresh == 0 {
+				expected = 2
+			}
+			if server.count() != expected {
+				t.Errorf("expected %d requests while the response is fresh, got %d", expected, server.count())
+			}
+
+			*now = now.Add(2 * time.Second)
+			testCachedGet(t, c, ts.URL, nil)
+			if server.count() != expected+1 {
+				t.Errorf("expected a stale response to be fetched again, got %d requests", server.count())
+			}
+		})
+	}
+}
+
+func TestResponseCache_NoStore(t *testing.T) {
+	noStore := true
+	server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=300")
+		if noStore {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = w.Write([]byte("secret"))
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, time.Hour)
+
+	testCachedGet(t, c, ts.URL, nil)
+	testCachedGet(t, c, ts.URL, nil)
+	if server.count() != 2 || server.last().Header.Get("If-None-Match") != "" {
+		t.Errorf("expected a no-store response not to be cached")
+	}
+
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected nothing to be written to the cache directory, got %d files", len(entries))
+	}
+
+	noStore = false
+	testCachedGet(t, c, ts.URL, nil)
+	testCachedGet(t, c, ts.URL, http.Header{"Cache-Control": {"no-store"}})
+	if server.count() != 4 {
+		t.Errorf("expected a no-store request to bypass the cache, got %d requests", server.count())
+	}
+}
+
+func TestResponseCache_Vary(t *testing.T) {
+	server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept")
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write([]byte(r.Header.Get("Accept")))
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, 0)
+
+	testCachedGet(t, c, ts.URL, http.Header{"Accept": {"application/json"}})
+	if _, body := testCachedGet(t, c, ts.URL, http.Header{"Accept": {"application/json"}}); body != "application/json" || server.count() != 1 {
+		t.Errorf("expected a request with the same Accept header to be answered from the cache, got %q after %d requests", body, server.count())
+	}
+
+	if _, body := testCachedGet(t, c, ts.URL, http.Header{"Accept": {"application/xml"}}); body != "application/xml" || server.count() != 2 {
+		t.Errorf("expected a request with another Accept header to be sent, got %q after %d requests", body, server.count())
+	}
+}
+
+func TestResponseCache_Authorization(t *testing.T) {
+	server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, 0)
+
+	testCachedGet(t, c, ts.URL, http.Header{"Authorization": {"Bearer a"}})
+	if _, body := testCachedGet(t, c, ts.URL, http.Header{"Authorization": {"Bearer b"}}); body != "Bearer b" {
+		t.Errorf("expected responses not to be shared across credentials, got %q", body)
+	}
+}
+
+func TestResponseCache_SignedRequests(t *testing.T) {
+	server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write([]byte("OK"))
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, 0)
+	client := &http.Client{Transport: c.wrap(http.DefaultTransport)}
+
+	// The signature and the key in the query change on every request.
+	timestamp := time.Unix(1700000000, 0)
+	signedGet := func(secret, credentialsID string) {
+		t.Helper()
+
+		request, err := http.NewRequest(http.MethodGet, ts.URL+"/items", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		timestamp = timestamp.Add(time.Second)
+		opts := backoffOptions{
+			MaxElapsedTime: 1,
+			Auth: []authenticator{
+				apiKeyAuth{query: "api_key", value: timestamp.String()},
+				&hmacSigner{algorithm: sha256.New, secret: secret, header: "X-Signature", template: defaultHMACTemplate, timestampHeader: "X-Timestamp", now: func() time.Time { return timestamp }},
+			},
+			CredentialsID: credentialsID,
+		}
+
+		response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), client, request, opts)
+		if errSummary != "" {
+			t.Fatalf("%s: %s", errSummary, errDesc)
+		}
+		drainBody(response)
+	}
+
+	for i := 0; i < 3; i++ {
+		signedGet("s3cret", "a")
+	}
+
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || server.count() != 1 {
+		t.Errorf("expected signed requests to share an entry, got %d entries after %d requests", len(entries), server.count())
+	}
+
+	signedGet("other", "b")
+	if server.count() != 2 {
+		t.Errorf("expected responses not to be shared across credentials, got %d requests", server.count())
+	}
+}
+
+func TestResponseCache_SuccessCondition(t *testing.T) {
+	server := &cacheTestServer{}
+	server.handler = func(w http.ResponseWriter, r *http.Request) {
+		if server.count() == 1 {
+			_, _ = w.Write([]byte(`{"status":"DOWN"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"UP"}`))
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, time.Minute)
+	client := &http.Client{Transport: c.wrap(http.DefaultTransport)}
+
+	request, err := http.NewRequest(http.MethodGet, ts.URL+"/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := backoffOptions{
+		InitialInterval:   10,
+		MaxElapsedTime:    5,
+		SuccessConditions: []successCondition{{JSONPath: "$.status", Equals: "UP"}},
+	}
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), client, request, opts)
+	if errSummary != "" {
+		t.Fatalf("%s: %s", errSummary, errDesc)
+	}
+	drainBody(response)
+
+	if server.count() != 2 {
+		t.Errorf("expected the retry not to be answered from the cache, got %d requests", server.count())
+	}
+	if _, body := testCachedGet(t, c, ts.URL+"/health", nil); body != `{"status":"UP"}` || server.count() != 2 {
+		t.Errorf("expected the last response to be cached, got %q after %d requests", body, server.count())
+	}
+}
+
+func TestResponseCache_Invalidation(t *testing.T) {
+	server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/items/1")
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			_, _ = w.Write([]byte(r.URL.Path))
+		}
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, time.Minute)
+	client := &http.Client{Transport: c.wrap(http.DefaultTransport)}
+	send := func(method, url string) {
+		t.Helper()
+
+		request, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(response)
+	}
+
+	for _, path := range []string{"/items", "/items/1", "/other"} {
+		testCachedGet(t, c, ts.URL+path, nil)
+	}
+
+	send(http.MethodPost, ts.URL+"/items")
+	for _, path := range []string{"/items", "/items/1", "/other"} {
+		testCachedGet(t, c, ts.URL+path, nil)
+	}
+	if server.count() != 6 {
+		t.Errorf("expected the request URL and the Location URL to be fetched again, got %d requests", server.count())
+	}
+
+	send(http.MethodDelete, ts.URL+"/other")
+	testCachedGet(t, c, ts.URL+"/other", nil)
+	if server.count() != 7 {
+		t.Errorf("expected a failed request not to invalidate the entry, got %d requests", server.count())
+	}
+}
+
+func TestResponseCache_Prune(t *testing.T) {
+	c, now := testCache(t, 0)
+	c.maxAge = 24 * time.Hour
+
+	files := map[string]time.Duration{
+		"4f2a7c9d1e0b3a5c6d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c":                                                                  time.Hour,
+		"0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c":                                                                  48 * time.Hour,
+		"0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c.123456.tmp":                                                       2 * time.Hour,
+		"4f2a7c9d1e0b3a5c6d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c-0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c": 48 * time.Hour,
+		"terraform.tfstate": 48 * time.Hour,
+	}
+	for name, age := range files {
+		path := filepath.Join(c.directory, name)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.prune()
+
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kept []string
+	for _, entry := range entries {
+		kept = append(kept, entry.Name())
+	}
+	if len(kept) != 2 || kept[0] != "4f2a7c9d1e0b3a5c6d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c" || kept[1] != "terraform.tfstate" {
+		t.Errorf("expected the stale entries to be removed, and the other files to be kept, got %v", kept)
+	}
+}
+
+func TestResponseCache_PartialBodyIsNotStored(t *testing.T) {
+	server := &cacheTestServer{handler: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=300")
+		_, _ = w.Write([]byte("a body that is not read to the end"))
+	}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c, _ := testCache(t, 0)
+
+	client := &http.Client{Transport: c.wrap(http.DefaultTransport)}
+	response, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = response.Body.Read(make([]byte, 4))
+	response.Body.Close()
+
+	testCachedGet(t, c, ts.URL, nil)
+	if server.count() != 2 {
+		t.Errorf("expected a partially read body not to be cached, got %d requests", server.count())
+	}
+}
+
+func TestResponseCache_Provider(t *testing.T) {
+	c := testApiClient(t, map[string]interface{}{
+		"cache": []interface{}{map[string]interface{}{"directory": t.TempDir(), "ttl": 60}},
+	})
+
+	if c.cache == nil || c.cache.ttl != time.Minute {
+		t.Fatalf("expected the cache block to be expanded, got %+v", c.cache)
+	}
+
+	if _, ok := c.client.Transport.(*cachingTransport); !ok {
+		t.Errorf("expected the shared client to go through the cache, got %T", c.client.Transport)
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":             "https://example.com",
+		"connect_timeout": 1000,
+	})
+
+	client, err := c.httpClient(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := client.Transport.(*cachingTransport); !ok {
+		t.Errorf("expected an overridden client to go through the cache, got %T", client.Transport)
+	}
+}
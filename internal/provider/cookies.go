@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookiesSchema returns the attributes that send cookies and keep the cookies
+// set by responses.
+func cookiesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"cookie_jar": {
+			Description: "Keeps the cookies set by responses and sends them back, for endpoints that rely on a session. " +
+				"The jar only lives for one read of the data source, or one operation of the resource. Always enabled with `pre_request`.",
+			Type:     schema.TypeBool,
+			Optional: true,
+		},
+
+		"cookies": {
+			Description: "A map of cookie names and values sent with the request.",
+			Type:        schema.TypeMap,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Optional:    true,
+			Sensitive:   true,
+		},
+
+		"pre_request": {
+			Description: "A request made once before the request itself, such as a login that sets a session cookie. " +
+				"It shares the cookie jar of the request, and is retried with the same backoff settings until it gets an expected status.",
+			Type:     schema.TypeList,
+			Optional: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"url": {
+						Description: "The URL of the request. A relative URL is appended to the `base_url` of the provider.",
+						Type:        schema.TypeString,
+						Required:    true,
+					},
+
+					"method": {
+						Description:  "The HTTP method of the request. Defaults to `POST`.",
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      http.MethodPost,
+						ValidateFunc: validation.StringInSlice(supportedMethods, false),
+					},
+
+					"request_headers": {
+						Description: "A map of request header field names and values.",
+						Type:        schema.TypeMap,
+						Elem:        &schema.Schema{Type: schema.TypeString},
+						Optional:    true,
+					},
+
+					"request_body": {
+						Description: "The request body as a string, such as login form fields.",
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+					},
+
+					"expected_status": {
+						Description: "The response status codes of a successful request, using the same syntax as `retry_on_status`. Defaults to `2xx` and `3xx`.",
+						Type:        schema.TypeSet,
+						Elem: &schema.Schema{
+							Type:         schema.TypeString,
+							ValidateFunc: validateStatusPattern,
+						},
+						Optional: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// addCookies adds the cookies of d to request.
+func addCookies(d *schema.ResourceData, request *http.Request) {
+	for name, value := range d.Get("cookies").(map[string]interface{}) {
+		request.AddCookie(&http.Cookie{Name: name, Value: value.(string)})
+	}
+}
+
+// sessionClient returns a copy of client with a cookie jar of its own when d
+// enables one, after making the pre_request of d with it.
+func (c *apiClient) sessionClient(ctx context.Context, d *schema.ResourceData, client *http.Client, opts backoffOptions) (*http.Client, string, string) {
+	pre := firstBlock(d.Get("pre_request"))
+	if pre == nil && !d.Get("cookie_jar").(bool) {
+		return client, "", ""
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, "Error creating the cookie jar", err.Error()
+	}
+
+	session := *client
+	session.Jar = jar
+
+	if pre == nil {
+		return &session, "", ""
+	}
+
+	request, err := http.NewRequestWithContext(ctx, pre["method"].(string), c.resolveURL(pre["url"].(string)), strings.NewReader(pre["request_body"].(string)))
+	if err != nil {
+		return nil, "Error creating pre_request", fmt.Sprintf("Error creating pre_request: %s", err)
+	}
+
+	for name, value := range pre["request_headers"].(map[string]interface{}) {
+		request.Header.Set(name, value.(string))
+	}
+	c.setDefaultHeaders(request)
+	addCookies(d, request)
+
+	// The pre_request only has to succeed: the checks of the response of the
+	// request itself do not apply to it.
+	opts.ExpectedStatus = expandStringList(pre["expected_status"].(*schema.Set).List())
+	if len(opts.ExpectedStatus) == 0 {
+		opts.ExpectedStatus = []string{"2xx", "3xx"}
+	}
+	opts.SuccessConditions = nil
+	opts.OutputFile = ""
+	opts.ExpectedSHA256 = ""
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(ctx, &session, request, opts)
+	if errSummary != "" {
+		return nil, "Error making pre_request: " + errSummary, errDesc
+	}
+	drainBody(response)
+
+	return &session, "", ""
+}
+
+// responseCookies returns the cookies set by response and by the redirects
+// that led to it, by name. Later cookies replace earlier ones.
+func responseCookies(response *http.Response) map[string]string {
+	var responses []*http.Response
+	for r := response; r != nil; {
+		responses = append([]*http.Response{r}, responses...)
+
+		if r.Request == nil {
+			break
+		}
+		r = r.Request.Response
+	}
+
+	cookies := map[string]string{}
+	for _, r := range responses {
+		for _, cookie := range r.Cookies() {
+			cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	return cookies
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testSessionServer is a stand-in legacy admin endpoint: /login sets a session
+// cookie, which /health requires, along with the theme cookie.
+func testSessionServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPost || string(body) != "user=admin&password=s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/", HttpOnly: true})
+			http.Redirect(w, r, "/home", http.StatusFound)
+		case "/home":
+			w.WriteHeader(http.StatusOK)
+		case "/health":
+			session, err := r.Cookie("session")
+			if err != nil || session.Value != "abc123" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if theme, err := r.Cookie("theme"); err != nil || theme.Value != "dark" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "checked", Value: "yes"})
+			http.Redirect(w, r, "/health/ok", http.StatusFound)
+		case "/health/ok":
+			http.SetCookie(w, &http.Cookie{Name: "status", Value: "ok"})
+			_, _ = w.Write([]byte("OK"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func testSessionRequest(t *testing.T, raw map[string]interface{}) (*apiClient, *http.Response, string) {
+	t.Helper()
+
+	c := testApiClient(t, nil)
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, raw)
+	opts := c.backoffOptions(d)
+
+	client, errSummary, errDesc := c.sessionClient(context.Background(), d, c.client, opts)
+	if errSummary != "" {
+		return c, nil, errSummary + ": " + errDesc
+	}
+
+	request, err := http.NewRequest(http.MethodGet, raw["url"].(string), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addCookies(d, request)
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), client, request, opts)
+	if errSummary != "" {
+		return c, nil, errSummary + ": " + errDesc
+	}
+	drainBody(response)
+
+	return c, response, ""
+}
+
+func TestCookies_PreRequestSharesJar(t *testing.T) {
+	server := testSessionServer(t)
+
+	c, response, errDesc := testSessionRequest(t, map[string]interface{}{
+		"url":     server.URL + "/health",
+		"cookies": map[string]interface{}{"theme": "dark"},
+		"pre_request": []interface{}{map[string]interface{}{
+			"url":             server.URL + "/login",
+			"method":          http.MethodPost,
+			"request_headers": map[string]interface{}{"Content-Type": "application/x-www-form-urlencoded"},
+			"request_body":    "user=admin&password=s3cret",
+		}},
+		"max_elapsed_time": 1,
+	})
+	if errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected the session cookie to be sent, got %s", response.Status)
+	}
+
+	cookies := responseCookies(response)
+	if len(cookies) != 2 || cookies["checked"] != "yes" || cookies["status"] != "ok" {
+		t.Errorf("expected the cookies set by the response and its redirect, got %v", cookies)
+	}
+
+	if c.client.Jar != nil {
+		t.Error("expected the jar to be scoped to the request, not to the shared client")
+	}
+}
+
+func TestCookies_WithoutSession(t *testing.T) {
+	server := testSessionServer(t)
+
+	_, response, errDesc := testSessionRequest(t, map[string]interface{}{
+		"url":        server.URL + "/health",
+		"cookies":    map[string]interface{}{"theme": "dark"},
+		"cookie_jar": true,
+	})
+	if errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the request to be rejected without logging in, got %s", response.Status)
+	}
+}
+
+func TestCookies_FailedPreRequest(t *testing.T) {
+	server := testSessionServer(t)
+
+	_, _, errDesc := testSessionRequest(t, map[string]interface{}{
+		"url": server.URL + "/health",
+		"pre_request": []interface{}{map[string]interface{}{
+			"url":          server.URL + "/login",
+			"method":       http.MethodPost,
+			"request_body": "user=admin&password=wrong",
+		}},
+		"retry_on_status":  []interface{}{"5xx"},
+		"max_elapsed_time": 1,
+	})
+
+	if !strings.HasPrefix(errDesc, "Error making pre_request") || !strings.Contains(errDesc, "401 Unauthorized") {
+		t.Errorf("expected the failed login to be reported, got %q", errDesc)
+	}
+}
@@ -2,17 +2,13 @@ package provider
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"mime"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -38,27 +34,30 @@ func dataSourceScaffolding() *schema.Resource {
 	return &schema.Resource{
 		// This description is used by the documentation generator and the language server.
 		Description: `
-		The ` + "`http`" + ` data source makes an HTTP GET request to the given URL and exports
-		information about the response.
+		The ` + "`http`" + ` data source makes an HTTP request to the given URL and exports
+		information about the response. The request uses the GET method unless ` + "`method`" + ` is set.
 		
 		The given URL may be either an ` + "`http`" + ` or ` + "`https`" + ` URL. At present this resource
-		can only retrieve data from URLs that respond with ` + "`text/*`" + ` or
-		` + "`application/json`" + ` content types, and expects the result to be UTF-8 encoded
-		regardless of the returned content type header.
+		can only retrieve data from URLs that respond with ` + "`text/*`" + `, JSON, YAML or
+		XML content types, and expects the result to be UTF-8 encoded regardless of the returned
+		content type header. JSON, YAML, XML and CSV bodies are also decoded into structured attributes.
+		Other content types, such as archives, can be accepted through ` + "`allowed_content_types`" + `, in which case
+		the body is only exported as base64 in ` + "`response_body_base64`" + `. Large bodies can instead be
+		streamed to ` + "`output_file`" + `, and verified against ` + "`expected_sha256`" + `.
 		
-		~> **Important** Although ` + "`https`" + ` URLs can be used, there is currently no
-		mechanism to authenticate the remote server except for general verification of
-		the server certificate's chain of trust. Data retrieved from servers not under
-		your control should be treated as untrustworthy.
+		~> **Important** Server certificates of ` + "`https`" + ` URLs are verified against the system
+		trust store, and against the CA certificates given in ` + "`ca_cert_pem`" + ` or ` + "`ca_cert_file`" + `.
+		Data retrieved from servers not under your control should be treated as untrustworthy.
 		
 		In addition to this there is possibility to configure exponential backoff retries that can be bounded
-		both by max elapsed time and max interval between retries.`,
+		both by max elapsed time and max interval between retries. Requests are retried on transport errors and
+		on the response status codes listed in ` + "`retry_on_status`" + `.`,
 
 		ReadContext: Read,
 
-		Schema: map[string]*schema.Schema{
+		Schema: mergeSchemas(map[string]*schema.Schema{
 			"url": {
-				Description: "The URL for the request. Supported schemes are `http` and `https`.",
+				Description: "The URL for the request. Supported schemes are `http`, `https` and `unix`, as in `unix:///var/run/app.sock:/status`. A relative URL is appended to the `base_url` of the provider.",
 				Type:        schema.TypeString,
 				Required:    true,
 			},
@@ -70,6 +69,14 @@ func dataSourceScaffolding() *schema.Resource {
 				Optional:    true,
 			},
 
+			"auth": authSchema(),
+
+			"oauth2": oauth2Schema("auth"),
+
+			"aws_sigv4": sigv4Schema("auth", "oauth2"),
+
+			"hmac_signature": hmacSchema(),
+
 			"response_body": {
 				Description: "The response body returned as a string.",
 				Type:        schema.TypeString,
@@ -91,49 +98,44 @@ func dataSourceScaffolding() *schema.Resource {
 				Computed:    true,
 			},
 
-			"id": {
-				Description: "The ID of this resource.",
+			"final_url": {
+				Description: "The URL of the response, after any redirect has been followed.",
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
 
-			"initial_interval": {
-				Description: "The initial exponential backoff interval.",
-				Type:        schema.TypeInt,
-				Optional:    true,
+			"redirect_chain": {
+				Description: "The URLs that redirected to `final_url`, in order, starting with `url`. Empty when no redirect was followed.",
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Computed:    true,
 			},
 
-			"max_elapsed_time": {
-				Description: "The maximum time to wait for.",
-				Type:        schema.TypeInt,
-				Optional:    true,
-			},
-			"randomization_factor": {
-				Description: "Randomization factor for exponential backoff.",
-				Type:        schema.TypeString,
-				Optional:    true,
+			"response_cookies": {
+				Description: "The cookies set by the response, and by the redirects that led to it, by name.",
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Computed:    true,
+				Sensitive:   true,
 			},
-			"multiplier": {
-				Description: "Multiplier for exponential backoff.",
+
+			"id": {
+				Description: "The final URL that was requested, after any redirect has been followed.",
 				Type:        schema.TypeString,
-				Optional:    true,
-			},
-			"max_interval": {
-				Description: "Maximum interval factor for exponential backoff.",
-				Type:        schema.TypeInt,
-				Optional:    true,
+				Computed:    true,
 			},
-		},
+		}, requestSchema(), redirectSchema(), cookiesSchema(), backoffSchema(), transportSchema(), responseFormatsSchema(), responseBodySchema(), downloadSchema()),
 	}
 }
 
 func Read(ctx context.Context, req *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	d := diag.Diagnostics{}
-	url := req.Get("url").(string)
+	c := meta.(*apiClient)
+	url := c.resolveURL(req.Get("url").(string))
 
 	headers := req.Get("request_headers").(map[string]interface{})
 
-	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	request, err := newRequestFromResourceData(ctx, req, url)
 	if err != nil {
 		d = append(d, diag.Diagnostic{
 			Summary: "Error creating request",
@@ -145,18 +147,29 @@ func Read(ctx context.Context, req *schema.ResourceData, meta interface{}) diag.
 	for name, value := range headers {
 		request.Header.Set(name, value.(string))
 	}
+	c.setDefaultHeaders(request)
+	addCookies(req, request)
+
+	client, err := c.httpClient(req)
+	if err != nil {
+		d = append(d, diag.Diagnostic{
+			Summary: "Error configuring the HTTP client",
+			Detail:  err.Error(),
+		})
+		return d
+	}
+
+	opts := c.backoffOptions(req)
+
+	client, errSummary, errDesc := c.sessionClient(ctx, req, client, opts)
+	if len(errSummary) > 0 {
+		d = append(d, diag.Diagnostic{Summary: errSummary, Detail: errDesc})
+		return d
+	}
 
 	var response *http.Response
-	tflog.Info(ctx, fmt.Sprintf("\nStarting.. requesting URL [%s] \n", url))
-	tflog.Info(ctx, fmt.Sprintf("\nRequest contents [%+v] \n", request))
-	response, errSummary, errDesc := makeExponentialBackoffRequest(ctx,
-		request,
-		int64(req.Get("initial_interval").(int)),
-		int64(req.Get("max_elapsed_time").(int)),
-		int64(req.Get("max_interval").(int)),
-		req.Get("randomization_factor").(string),
-		req.Get("multiplier").(string),
-	)
+	tflog.Info(ctx, fmt.Sprintf("\nStarting.. requesting URL [%s] \n", redactURL(request.URL)))
+	response, errSummary, errDesc = makeExponentialBackoffRequest(ctx, client, request, opts)
 
 	if len(errSummary) > 0 {
 		d = append(d, diag.Diagnostic{Summary: errSummary, Detail: errDesc})
@@ -172,11 +185,17 @@ func Read(ctx context.Context, req *schema.ResourceData, meta interface{}) diag.
 		defer response.Body.Close()
 	}
 
+	// A body saved to output_file has already been streamed to disk by the
+	// retry loop, whatever its content type.
+	outputFile := req.Get("output_file").(string)
+
 	contentType := response.Header.Get("Content-Type")
-	if !isContentTypeText(contentType) {
+	isText := outputFile == "" && isContentTypeText(contentType)
+	if outputFile == "" && !isText && !isContentTypeAllowed(contentType, expandStringList(req.Get("allowed_content_types").([]interface{}))) {
 		d = append(d, diag.Diagnostic{
 			Summary: fmt.Sprintf("Content-Type is not recognized as a text type, got %q", contentType),
-			Detail:  "If the content is binary data, Terraform may not properly handle the contents of the response.",
+			Detail: "If the content is binary data, Terraform may not properly handle the contents of the response. " +
+				"List the content type in allowed_content_types to read it through response_body_base64 instead.",
 		})
 		return d
 	}
@@ -191,7 +210,12 @@ func Read(ctx context.Context, req *schema.ResourceData, meta interface{}) diag.
 		return d
 	}
 
-	responseBody := string(bytes)
+	// Binary content is only exported in response_body_base64, as it would
+	// not survive the conversion to a string.
+	responseBody := ""
+	if isText {
+		responseBody = string(bytes)
+	}
 
 	responseHeaders := make(map[string]string)
 	for k, v := range response.Header {
@@ -202,6 +226,68 @@ func Read(ctx context.Context, req *schema.ResourceData, meta interface{}) diag.
 
 	tflog.Info(ctx, fmt.Sprintf("%v", responseBody))
 
+	if err = req.Set("response_body", responseBody); err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting response_body", Detail: err.Error()})
+		return d
+	}
+
+	if err = req.Set("response_headers", responseHeaders); err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting response_headers", Detail: err.Error()})
+		return d
+	}
+
+	if err = req.Set("status_code", response.StatusCode); err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting status_code", Detail: err.Error()})
+		return d
+	}
+
+	if outputFile != "" {
+		err = setResponseFileChecksums(req, outputFile)
+	} else {
+		err = setResponseBodyEncodings(req, bytes, isText)
+	}
+	if err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting the response body encodings", Detail: err.Error()})
+		return d
+	}
+
+	if isText {
+		if err = setResponseFormats(req, contentType, bytes); err != nil {
+			d = append(d, diag.Diagnostic{Summary: "Error decoding response body", Detail: err.Error()})
+			return d
+		}
+	}
+
+	// The first URL of the chain is the one of the attempt, which may carry
+	// credentials in its query, so the URL of the request is used instead.
+	chain := redirectChain(response)
+	chain[0] = request.URL
+
+	urls := make([]string, len(chain))
+	for i, u := range chain {
+		urls[i] = u.String()
+	}
+	finalURL := urls[len(urls)-1]
+
+	if err = req.Set("final_url", finalURL); err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting final_url", Detail: err.Error()})
+		return d
+	}
+
+	if err = req.Set("redirect_chain", urls[:len(urls)-1]); err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting redirect_chain", Detail: err.Error()})
+		return d
+	}
+
+	if err = req.Set("response_cookies", responseCookies(response)); err != nil {
+		d = append(d, diag.Diagnostic{Summary: "Error setting response_cookies", Detail: err.Error()})
+		return d
+	}
+
+	// The final URL is used as the ID so that it stays stable across reads
+	// of the same endpoint, and reflects any redirect that was followed.
+	req.SetId(finalURL)
+
 	return nil
 }
 
@@ -218,7 +304,12 @@ func isContentTypeText(contentType string) bool {
 	allowedContentTypes := []*regexp.Regexp{
 		regexp.MustCompile("^text/.+"),
 		regexp.MustCompile("^application/json$"),
+		regexp.MustCompile(`^application/.+\+json$`),
+		regexp.MustCompile("^application/(x-)?yaml$"),
+		regexp.MustCompile(`^application/.+\+yaml$`),
+		regexp.MustCompile("^application/xml$"),
 		regexp.MustCompile(`^application/samlmetadata\+xml`),
+		regexp.MustCompile(`^application/.+\+xml$`),
 	}
 
 	for _, r := range allowedContentTypes {
@@ -230,65 +321,3 @@ func isContentTypeText(contentType string) bool {
 
 	return false
 }
-
-func makeExponentialBackoffRequest(ctx context.Context, request *http.Request, initialInterval, maxElapsedTime, maxInterval int64, randomization_fct, multpl string) (*http.Response, string, string) {
-	var randomization_factor, multiplier float64
-	var err error
-	client := &http.Client{}
-
-	randomization_factor = backoff.DefaultRandomizationFactor
-	multiplier = backoff.DefaultMultiplier
-
-	if initialInterval == 0 {
-		initialInterval = int64(backoff.DefaultInitialInterval)
-	}
-
-	if maxElapsedTime == 0 {
-		maxElapsedTime = int64(60)
-	}
-
-	if maxInterval == 0 {
-		maxInterval = int64(backoff.DefaultMaxInterval)
-	}
-
-	if len(randomization_fct) > 0 {
-		randomization_factor, err = strconv.ParseFloat(randomization_fct, 64)
-		if err != nil {
-			return nil, "error converting randomization_factor to float64", fmt.Sprintf("%s", err)
-		}
-	}
-
-	if len(multpl) > 0 {
-		multiplier, err = strconv.ParseFloat(multpl, 64)
-		if err != nil {
-			return nil, "error converting multiplier to float64", fmt.Sprintf("%s", err)
-		}
-	}
-
-	b := backoff.NewExponentialBackOff()
-	b.MaxElapsedTime = time.Duration(maxElapsedTime) * time.Second
-	b.InitialInterval = time.Duration(initialInterval) * time.Millisecond
-	b.RandomizationFactor = randomization_factor
-	b.Multiplier = multiplier
-	b.MaxInterval = time.Duration(maxInterval) * time.Millisecond
-	s, err := json.MarshalIndent(b, "", "   ")
-	tflog.Info(ctx, fmt.Sprintf("Backoff configuration :  %s", s))
-
-	retries := 0
-	var response *http.Response
-	err = backoff.Retry(func() error {
-		tflog.Info(ctx, fmt.Sprintf("\nCalling http.Do URL : [%+v]\n", request))
-		response, err = client.Do(request)
-		fmt.Printf("in backoff response pointer %p", response)
-		tflog.Info(ctx, fmt.Sprintf("\nNumber of retries %d\n", retries))
-		tflog.Info(ctx, fmt.Sprintf("\nError %v\n", err))
-		retries++
-		return err
-	}, b)
-
-	if err != nil {
-		return nil, "Error making request", fmt.Sprintf("Error making request: %s", err)
-	}
-
-	return response, "", ""
-}
@@ -2,13 +2,18 @@ package provider
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestDataSource_200(t *testing.T) {
@@ -369,10 +374,610 @@ func TestDataSource_RegisteredDomainBackoff(t *testing.T) {
 	})
 }
 
+func TestDataSource_HttpWait_200(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s/200"
+							}`, testHttpMock.server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "1.0.0"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_headers.Content-Type", "text/plain"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_headers.X-Single", "foobar"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_headers.X-Double", "1, 2"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "id", fmt.Sprintf("%s/200", testHttpMock.server.URL)),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_404(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s/404"
+							}`, testHttpMock.server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", ""),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_headers.Content-Type", "text/plain"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "404"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "id", fmt.Sprintf("%s/404", testHttpMock.server.URL)),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_withAuthorizationRequestHeader_200(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s/restricted"
+
+								request_headers = {
+									"Authorization" = "Zm9vOmJhcg=="
+								}
+							}`, testHttpMock.server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "1.0.0"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_RetryOnStatus(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "text/plain")
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("booting"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready"))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s"
+								max_elapsed_time = 10
+								initial_interval = 100
+								retry_on_status  = ["5xx"]
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "ready"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_RetryAfter(t *testing.T) {
+	attempts := 0
+	var throttledAt time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "text/plain")
+		if attempts == 1 {
+			throttledAt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if time.Since(throttledAt) < time.Second {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready"))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s"
+								max_elapsed_time = 10
+								initial_interval = 10
+								retry_on_status  = ["429"]
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "ready"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_PostWithRetry(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		if attempts < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s"
+								method           = "POST"
+								request_body     = "warm-up"
+								max_elapsed_time = 10
+								initial_interval = 100
+								retry_on_status  = ["503"]
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "POST warm-up"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_SuccessCondition(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		status := "DOWN"
+		if attempts >= 3 {
+			status = "UP"
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"status": %q}`, status)))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s"
+								max_elapsed_time = 10
+								initial_interval = 100
+
+								success_condition {
+									json_path = "$.status"
+									equals    = "UP"
+								}
+
+								success_condition {
+									regex = ""status":\s*"UP""
+								}
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", `{"status": "UP"}`),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_SuccessConditionNotMet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "DOWN"}`))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s"
+								max_elapsed_time = 2
+								initial_interval = 100
+
+								success_condition {
+									jmespath = "status == 'UP'"
+								}
+							}`, server.URL),
+				ExpectError: regexp.MustCompile(`success_condition 0 \(jmespath status == 'UP'\) not met`),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_ProviderDefaults(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							provider "http-wait" {
+								base_url         = "%s"
+								max_elapsed_time = 10
+								initial_interval = 100
+
+								default_headers = {
+									"Authorization" = "Zm9vOmJhcg=="
+								}
+							}
+
+							data "http-wait" "http_test" {
+								url = "restricted"
+							}
+
+							data "http-wait" "http_override" {
+								url = "restricted"
+
+								request_headers = {
+									"Authorization" = "unauthorized"
+								}
+							}`, testHttpMock.server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "1.0.0"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+					resource.TestCheckResourceAttr("data.http-wait.http_override", "status_code", "403"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_CACertPEM(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("trusted"))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url         = "%s"
+								ca_cert_pem = <<EOT
+%sEOT
+							}`, server.URL, testServerCertPEM(server)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "trusted"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_UnexpectedStatus(t *testing.T) {
+	testHttpMock := setUpMockHttpServer()
+	defer testHttpMock.server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s/restricted"
+								max_elapsed_time = 10
+								initial_interval = 100
+								retry_on_status  = ["5xx"]
+								expected_status  = ["200"]
+							}`, testHttpMock.server.URL),
+				ExpectError: regexp.MustCompile("unexpected response status 403"),
+			},
+		},
+	})
+}
+
 type TestHttpMock struct {
 	server *httptest.Server
 }
 
+func TestDataSource_HttpWait_ResponseJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": "1.2.3", "regions": [{"name": "eu-west-1"}, {"name": "us-east-1"}]}`))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s"
+
+								json_fields = {
+									version     = "$.version"
+									last_region = "$.regions[-1].name"
+								}
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_json.version", "1.2.3"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_json.regions.0.name", "eu-west-1"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_json_fields.version", "1.2.3"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_json_fields.last_region", "us-east-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_ResponseFormats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flags.yaml":
+			w.Header().Set("Content-Type", "application/yaml")
+			_, _ = w.Write([]byte("flags:\n  new-checkout: true\n"))
+		case "/status.xml":
+			w.Header().Set("Content-Type", "text/xml")
+			_, _ = w.Write([]byte(`<service><status>UP</status></service>`))
+		case "/regions.csv":
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = w.Write([]byte("region,status\neu-west-1,UP\n"))
+		}
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "yaml" {
+								url = "%[1]s/flags.yaml"
+							}
+
+							data "http-wait" "xml" {
+								url = "%[1]s/status.xml"
+
+								xml_fields = {
+									status = "/service/status"
+								}
+							}
+
+							data "http-wait" "csv" {
+								url = "%[1]s/regions.csv"
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.yaml", "response_yaml.flags.new-checkout", "true"),
+					resource.TestCheckResourceAttr("data.http-wait.xml", "response_xml_fields.status", "UP"),
+					resource.TestCheckResourceAttr("data.http-wait.csv", "response_csv.#", "1"),
+					resource.TestCheckResourceAttr("data.http-wait.csv", "response_csv.0.region", "eu-west-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_BinaryContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte{0x00, 0xff, 0x10})
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s"
+							}`, server.URL),
+				ExpectError: regexp.MustCompile("Content-Type is not recognized as a text type"),
+			},
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url                   = "%s"
+								allowed_content_types = ["application/*"]
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", ""),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body_base64", "AP8Q"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body_md5", "481e4551ec039aada760901cf52b1917"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_OutputFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "artifact.tar.gz")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url             = "%s"
+								output_file     = "%s"
+								expected_sha256 = "%s"
+							}`, server.URL, outputFile, testSHA256("artifact")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", ""),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body_sha256", testSHA256("artifact")),
+					func(*terraform.State) error {
+						content, err := os.ReadFile(outputFile)
+						if err != nil {
+							return err
+						}
+						if string(content) != "artifact" {
+							return fmt.Errorf("unexpected output file content %q", content)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_BearerAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("1.0.0"))
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s"
+
+								auth {
+									bearer {
+										token = "t0ken"
+									}
+								}
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "1.0.0"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "200"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_Redirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/docs":
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		case "/docs/":
+			http.Redirect(w, r, "/docs/v2/", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("docs"))
+		}
+	}))
+	defer server.Close()
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url = "%s/go/docs"
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "final_url", server.URL+"/docs/v2/"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "redirect_chain.#", "2"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "redirect_chain.0", server.URL+"/go/docs"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "redirect_chain.1", server.URL+"/docs/"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url              = "%s/go/docs"
+								follow_redirects = false
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "status_code", "301"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_headers.Location", "/docs/"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "final_url", server.URL+"/go/docs"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "redirect_chain.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSource_HttpWait_PreRequest(t *testing.T) {
+	server := testSessionServer(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+							data "http-wait" "http_test" {
+								url     = "%[1]s/health"
+								cookies = {
+									theme = "dark"
+								}
+
+								pre_request {
+									url          = "%[1]s/login"
+									request_body = "user=admin&password=s3cret"
+								}
+							}`, server.URL),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_body", "OK"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_cookies.checked", "yes"),
+					resource.TestCheckResourceAttr("data.http-wait.http_test", "response_cookies.status", "ok"),
+				),
+			},
+		},
+	})
+}
+
 func setUpMockHttpServer() *TestHttpMock {
 	Server := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
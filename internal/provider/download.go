@@ -0,0 +1,288 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// errResponseTooLarge is returned for a response body larger than
+// max_response_bytes, which retrying would not fix.
+var errResponseTooLarge = errors.New("response body exceeds max_response_bytes")
+
+// downloadSchema returns the attributes that control how the response body
+// is read, and whether it is streamed to a file instead of kept in memory.
+func downloadSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"output_file": {
+			Description: "Path of a file the response body is streamed to instead of being kept in memory and in the state. " +
+				"The body is written to a temporary file in the same directory, which is renamed to `output_file` once complete.",
+			Type:          schema.TypeString,
+			Optional:      true,
+			ConflictsWith: []string{"success_condition"},
+		},
+
+		"expected_sha256": {
+			Description: "The hex encoded SHA-256 checksum the response body must have. A body with another checksum, " +
+				"or that is shorter than announced, is downloaded again through the backoff retry loop.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[0-9a-fA-F]{64}$`), "must be a hex encoded SHA-256 checksum"),
+		},
+
+		"resume_download": {
+			Description: "Whether an interrupted download to `output_file` is resumed by the next attempt with a range request, " +
+				"instead of starting over. This requires the server to send a strong `ETag` or a `Last-Modified` header.",
+			Type:         schema.TypeBool,
+			Optional:     true,
+			RequiredWith: []string{"output_file"},
+		},
+
+		"max_response_bytes": {
+			Description:  "The maximum size in bytes of the response body. A larger body fails the request without retrying. Unlimited by default.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+	}
+}
+
+// download streams the response bodies of the retry loop to a file.
+type download struct {
+	path           string
+	expectedSHA256 string
+	maxBytes       int64
+	resume         bool
+
+	// partial is the temporary file the body is written to, next to path.
+	// When resume is set it is kept across attempts, along with the hash
+	// and the length of its content, and the validator of the response it
+	// came from, so that an interrupted body can be completed with a range
+	// request instead of being downloaded again.
+	partial   *os.File
+	hash      hash.Hash
+	written   int64
+	validator string
+}
+
+// prepare asks for the rest of the body of an earlier attempt, provided it
+// is still the same representation.
+func (dl *download) prepare(request *http.Request) {
+	if dl.written > 0 && dl.validator != "" {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", dl.written))
+		request.Header.Set("If-Range", dl.validator)
+	}
+}
+
+// checkResume reports whether response continues the body of an earlier
+// attempt, whose status was checked already.
+func (dl *download) checkResume(response *http.Response) (bool, error) {
+	if dl.written == 0 || dl.validator == "" {
+		return false, nil
+	}
+
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+		return true, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		drainBody(response)
+		dl.discard()
+		return true, fmt.Errorf("cannot resume the download: unexpected response status %s", response.Status)
+	}
+
+	return false, nil
+}
+
+// save writes the body of response to the partial file, and renames it to
+// the output file once the body is complete and has the expected checksum.
+// The body of response is replaced with an empty one.
+func (dl *download) save(response *http.Response) error {
+	defer response.Body.Close()
+
+	length := response.ContentLength
+	if dl.validator != "" && dl.written > 0 && response.StatusCode == http.StatusPartialContent {
+		start, total, ok := parseContentRange(response.Header.Get("Content-Range"))
+		if !ok || start != dl.written {
+			dl.discard()
+			return fmt.Errorf("cannot resume the download: unexpected Content-Range %q", response.Header.Get("Content-Range"))
+		}
+		length = total
+	} else {
+		if err := dl.reset(); err != nil {
+			return backoff.Permanent(fmt.Errorf("error creating output_file: %w", err))
+		}
+
+		if dl.resume {
+			dl.validator = rangeValidator(response.Header)
+		}
+	}
+
+	if dl.maxBytes > 0 && length > dl.maxBytes {
+		dl.discard()
+		return backoff.Permanent(errResponseTooLarge)
+	}
+
+	var body io.Reader = response.Body
+	if dl.maxBytes > 0 {
+		body = limitBody(response.Body, dl.maxBytes-dl.written)
+	}
+
+	written, err := io.Copy(io.MultiWriter(dl.partial, dl.hash), body)
+	dl.written += written
+	if err != nil {
+		return fmt.Errorf("error downloading response body after %d bytes: %w", dl.written, err)
+	}
+
+	if dl.maxBytes > 0 && dl.written > dl.maxBytes {
+		dl.discard()
+		return backoff.Permanent(errResponseTooLarge)
+	}
+
+	if length >= 0 && dl.written != length {
+		return fmt.Errorf("short read: got %d bytes out of %d", dl.written, length)
+	}
+
+	if err := checkSHA256(dl.hash.Sum(nil), dl.expectedSHA256); err != nil {
+		// The partial file cannot tell which bytes are wrong.
+		dl.discard()
+		return err
+	}
+
+	if err := dl.commit(); err != nil {
+		return backoff.Permanent(fmt.Errorf("error writing output_file: %w", err))
+	}
+
+	response.Body = http.NoBody
+
+	return nil
+}
+
+// reset empties the partial file, creating it if needed.
+func (dl *download) reset() error {
+	dl.hash = sha256.New()
+	dl.written = 0
+	dl.validator = ""
+
+	if dl.partial == nil {
+		partial, err := os.CreateTemp(filepath.Dir(dl.path), "."+filepath.Base(dl.path)+".*.tmp")
+		if err != nil {
+			return err
+		}
+		dl.partial = partial
+		return nil
+	}
+
+	if err := dl.partial.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err := dl.partial.Seek(0, io.SeekStart)
+	return err
+}
+
+// commit renames the complete partial file to the output file.
+func (dl *download) commit() error {
+	partial := dl.partial
+	dl.partial = nil
+	dl.written = 0
+	dl.validator = ""
+
+	defer os.Remove(partial.Name())
+	defer partial.Close()
+
+	if err := partial.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if err := partial.Sync(); err != nil {
+		return err
+	}
+
+	if err := partial.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partial.Name(), dl.path)
+}
+
+// discard removes the partial file, so that the next attempt starts over.
+func (dl *download) discard() {
+	dl.written = 0
+	dl.validator = ""
+
+	if dl.partial != nil {
+		_ = dl.partial.Close()
+		_ = os.Remove(dl.partial.Name())
+		dl.partial = nil
+	}
+}
+
+// rangeValidator returns the value of the If-Range header that makes sure a
+// range request continues the representation of the response with header.
+// Only strong entity tags can be used, and dates are a fallback.
+func rangeValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return header.Get("Last-Modified")
+}
+
+// parseContentRange parses a Content-Range header such as bytes 100-199/200
+// into the position of the first byte and the total length, which is -1 when
+// it is unknown.
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	var first, last int64
+	var total string
+
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &first, &last, &total); err != nil || first > last {
+		return 0, 0, false
+	}
+
+	if total == "*" {
+		return first, -1, true
+	}
+
+	length, err := strconv.ParseInt(total, 10, 64)
+	if err != nil || length <= last {
+		return 0, 0, false
+	}
+
+	return first, length, true
+}
+
+// limitBody returns a reader of body that stops one byte past maxBytes, so
+// that a body that is too large can be told apart without reading it all.
+func limitBody(body io.Reader, maxBytes int64) io.Reader {
+	if maxBytes <= 0 {
+		return body
+	}
+
+	return io.LimitReader(body, maxBytes+1)
+}
+
+// checkSHA256 returns an error when expected is set and differs from sum.
+func checkSHA256(sum []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	if got := hex.EncodeToString(sum); got != strings.ToLower(expected) {
+		return fmt.Errorf("response body checksum mismatch: expected sha256 %s, got %s", strings.ToLower(expected), got)
+	}
+
+	return nil
+}
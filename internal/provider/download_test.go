@@ -0,0 +1,375 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testSHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// testDownload runs the retry loop against url and returns the description
+// of the error it failed with, if any.
+func testDownload(t *testing.T, url string, opts backoffOptions) string {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.InitialInterval = 10
+	opts.MaxElapsedTime = 2
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), http.DefaultClient, request, opts)
+	if errSummary != "" {
+		return errDesc
+	}
+	drainBody(response)
+
+	return ""
+}
+
+// assertNoTempFiles fails when a temporary file was left behind in dir.
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s was left behind", entry.Name())
+		}
+	}
+}
+
+func TestDownload_ChecksumMismatchIsRetried(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			_, _ = w.Write([]byte("stale artifact"))
+			return
+		}
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "artifact.bin")
+
+	if errDesc := testDownload(t, server.URL, backoffOptions{OutputFile: outputFile, ExpectedSHA256: testSHA256("artifact")}); errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "artifact" {
+		t.Errorf("expected the output file to hold the verified body, got %q", content)
+	}
+
+	if attempts != 2 {
+		t.Errorf("expected the mismatching body to be downloaded again, got %d attempts", attempts)
+	}
+
+	assertNoTempFiles(t, dir)
+}
+
+func TestDownload_ShortReadIsRetried(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Length", strconv.Itoa(len("artifact")))
+		if attempts == 1 {
+			// Drop the connection halfway through the announced body.
+			_, _ = w.Write([]byte("arti"))
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "artifact.bin")
+
+	if errDesc := testDownload(t, server.URL, backoffOptions{OutputFile: outputFile}); errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "artifact" || attempts != 2 {
+		t.Errorf("expected the truncated body to be downloaded again, got %q after %d attempts", content, attempts)
+	}
+
+	assertNoTempFiles(t, dir)
+}
+
+func TestDownload_MaxResponseBytes(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Stream the body so that its length is not known up front.
+		w.Header().Set("Transfer-Encoding", "chunked")
+		_, _ = w.Write([]byte("a body that is too large"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "artifact.bin")
+
+	for _, opts := range []backoffOptions{
+		{MaxResponseBytes: 8},
+		{MaxResponseBytes: 8, OutputFile: outputFile},
+	} {
+		attempts = 0
+
+		errDesc := testDownload(t, server.URL, opts)
+		if !strings.Contains(errDesc, "exceeds max_response_bytes") {
+			t.Errorf("expected max_response_bytes to be enforced, got %q", errDesc)
+		}
+
+		if attempts != 1 {
+			t.Errorf("expected a body that is too large not to be retried, got %d attempts", attempts)
+		}
+	}
+
+	if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+		t.Errorf("expected no output file for a body that is too large, got %v", err)
+	}
+
+	assertNoTempFiles(t, dir)
+
+	if errDesc := testDownload(t, server.URL, backoffOptions{MaxResponseBytes: 64}); errDesc != "" {
+		t.Errorf("expected a body within max_response_bytes to be accepted, got %s", errDesc)
+	}
+}
+
+func TestDownload_InMemoryChecksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("artifact"))
+	}))
+	defer server.Close()
+
+	if errDesc := testDownload(t, server.URL, backoffOptions{ExpectedSHA256: strings.ToUpper(testSHA256("artifact"))}); errDesc != "" {
+		t.Errorf("expected the checksum to match, got %s", errDesc)
+	}
+
+	if errDesc := testDownload(t, server.URL, backoffOptions{ExpectedSHA256: testSHA256("other")}); !strings.Contains(errDesc, "checksum mismatch") {
+		t.Errorf("expected a checksum mismatch, got %q", errDesc)
+	}
+}
+
+// resumableServer serves content with http.ServeContent, which honours Range
+// and If-Range headers, and drops the connection after dropAfter[i] bytes of
+// the body in the i-th attempt.
+type resumableServer struct {
+	content   []byte
+	etag      func(attempt int) string
+	dropAfter []int
+
+	// mu guards the fields below, as a handler may still be running when
+	// the client has moved on to the next attempt.
+	mu       sync.Mutex
+	attempts []*http.Request
+	served   int
+}
+
+func (s *resumableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := len(s.attempts)
+	s.attempts = append(s.attempts, r)
+
+	w.Header().Set("ETag", s.etag(attempt))
+
+	rw := &droppingWriter{ResponseWriter: w, remaining: -1}
+	if attempt < len(s.dropAfter) {
+		rw.remaining = s.dropAfter[attempt]
+	}
+
+	http.ServeContent(rw, r, "", time.Time{}, bytes.NewReader(s.content))
+	s.served += rw.written
+}
+
+// droppingWriter writes up to remaining bytes of the body, then closes the
+// connection without completing the response. A negative remaining writes
+// the whole body.
+type droppingWriter struct {
+	http.ResponseWriter
+	remaining int
+	written   int
+}
+
+func (w *droppingWriter) Write(p []byte) (int, error) {
+	if w.remaining < 0 {
+		n, err := w.ResponseWriter.Write(p)
+		w.written += n
+		return n, err
+	}
+
+	if len(p) > w.remaining {
+		p = p[:w.remaining]
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.written += n
+	w.remaining -= n
+
+	if w.remaining == 0 {
+		w.ResponseWriter.(http.Flusher).Flush()
+		if conn, _, err := w.ResponseWriter.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+		return n, errors.New("connection dropped")
+	}
+
+	return n, err
+}
+
+func TestDownload_Resume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+	testCases := []struct {
+		name      string
+		etag      func(attempt int) string
+		dropAfter []int
+		ranges    []string
+		served    int
+	}{
+		{
+			name:      "single interruption",
+			etag:      func(int) string { return `"v1"` },
+			dropAfter: []int{300000},
+			ranges:    []string{"", "bytes=300000-"},
+			served:    len(content),
+		},
+		{
+			name:      "repeated interruptions",
+			etag:      func(int) string { return `"v1"` },
+			dropAfter: []int{300000, 200000},
+			ranges:    []string{"", "bytes=300000-", "bytes=500000-"},
+			served:    len(content),
+		},
+		{
+			name: "changed representation",
+			etag: func(attempt int) string {
+				if attempt == 0 {
+					return `"v1"`
+				}
+				return `"v2"`
+			},
+			dropAfter: []int{300000},
+			ranges:    []string{"", "bytes=300000-"},
+			served:    300000 + len(content),
+		},
+		{
+			name:      "weak entity tag",
+			etag:      func(int) string { return `W/"v1"` },
+			dropAfter: []int{300000},
+			ranges:    []string{"", ""},
+			served:    300000 + len(content),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &resumableServer{content: content, etag: tc.etag, dropAfter: tc.dropAfter}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			dir := t.TempDir()
+			outputFile := filepath.Join(dir, "artifact.bin")
+
+			sum := sha256.Sum256(content)
+			if errDesc := testDownload(t, server.URL, backoffOptions{
+				OutputFile:     outputFile,
+				ResumeDownload: true,
+				ExpectedSHA256: hex.EncodeToString(sum[:]),
+			}); errDesc != "" {
+				t.Fatal(errDesc)
+			}
+
+			saved, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(saved, content) {
+				t.Errorf("expected the output file to hold the whole body, got %d bytes", len(saved))
+			}
+
+			handler.mu.Lock()
+			defer handler.mu.Unlock()
+
+			var ranges []string
+			for i, r := range handler.attempts {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if r.Header.Get("Range") != "" && r.Header.Get("If-Range") != tc.etag(i-1) {
+					t.Errorf("expected attempt %d to be conditional on %s, got If-Range %q", i, tc.etag(i-1), r.Header.Get("If-Range"))
+				}
+			}
+
+			if strings.Join(ranges, ",") != strings.Join(tc.ranges, ",") {
+				t.Errorf("expected Range headers %q, got %q", tc.ranges, ranges)
+			}
+
+			if handler.served != tc.served {
+				t.Errorf("expected %d bytes to be served, got %d", tc.served, handler.served)
+			}
+
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	testCases := []struct {
+		contentRange string
+		first, total int64
+		ok           bool
+	}{
+		{contentRange: "bytes 100-199/200", first: 100, total: 200, ok: true},
+		{contentRange: "bytes 0-0/1", first: 0, total: 1, ok: true},
+		{contentRange: "bytes 100-199/*", first: 100, total: -1, ok: true},
+		{contentRange: "bytes 100-199/150", ok: false},
+		{contentRange: "bytes 200-100/300", ok: false},
+		{contentRange: "bytes */200", ok: false},
+		{contentRange: "", ok: false},
+	}
+
+	for _, tc := range testCases {
+		first, total, ok := parseContentRange(tc.contentRange)
+		if ok != tc.ok || (ok && (first != tc.first || total != tc.total)) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %t, expected %d, %d, %t", tc.contentRange, first, total, ok, tc.first, tc.total, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// hmacAlgorithms are the hash functions an hmac_signature block can use.
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// defaultHMACTemplate is the string signed when the template is not set.
+const defaultHMACTemplate = "{method}\n{path}\n{timestamp}\n{body}"
+
+// hmacSchema returns the block that signs requests with an HMAC of their
+// method, path, timestamp and body, as webhook style endpoints expect.
+func hmacSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Signs every attempt with an HMAC of a string built from the request, sent in a request header.",
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"algorithm": {
+					Description:  "The hash function of the HMAC, one of `sha1`, `sha256` (default) or `sha512`.",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "sha256",
+					ValidateFunc: validation.StringInSlice([]string{"sha1", "sha256", "sha512"}, false),
+				},
+
+				"secret": {
+					Description: "The secret key of the HMAC.",
+					Type:        schema.TypeString,
+					Required:    true,
+					Sensitive:   true,
+				},
+
+				"header": {
+					Description: "The request header the signature is sent in.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					Default:     "X-Signature",
+				},
+
+				"template": {
+					Description: "The string to sign. `{method}`, `{host}`, `{path}`, `{query}`, `{timestamp}`, `{body}` and " +
+						"`{body_sha256}` are replaced with the values of the attempt. Defaults to the method, path, timestamp and body separated by newlines.",
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  defaultHMACTemplate,
+				},
+
+				"timestamp_header": {
+					Description: "The request header the Unix timestamp of the attempt is sent in, such as `X-Timestamp`. When unset, the timestamp is only part of the signed string.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"encoding": {
+					Description:  "The encoding of the signature, `hex` (default) or `base64`.",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "hex",
+					ValidateFunc: validation.StringInSlice([]string{"hex", "base64"}, false),
+				},
+
+				"prefix": {
+					Description: "A prefix of the header value, such as `sha256=`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+			},
+		},
+	}
+}
+
+// hmacSigner sets an HMAC of a string built from the request of an attempt,
+// and the timestamp it covers, on every attempt.
+type hmacSigner struct {
+	algorithm       func() hash.Hash
+	secret          string
+	header          string
+	template        string
+	timestampHeader string
+	encoding        string
+	prefix          string
+	now             func() time.Time
+}
+
+func expandHMAC(d *schema.ResourceData) *hmacSigner {
+	block := firstBlock(d.Get("hmac_signature"))
+	if block == nil {
+		return nil
+	}
+
+	return &hmacSigner{
+		algorithm:       hmacAlgorithms[block["algorithm"].(string)],
+		secret:          block["secret"].(string),
+		header:          block["header"].(string),
+		template:        block["template"].(string),
+		timestampHeader: block["timestamp_header"].(string),
+		encoding:        block["encoding"].(string),
+		prefix:          block["prefix"].(string),
+		now:             time.Now,
+	}
+}
+
+func (s *hmacSigner) authenticate(request *http.Request) error {
+	body, err := requestBody(request)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+
+	message := strings.NewReplacer(
+		"{method}", request.Method,
+		"{host}", host,
+		"{path}", path,
+		"{query}", request.URL.RawQuery,
+		"{timestamp}", timestamp,
+		"{body}", string(body),
+		"{body_sha256}", sha256Hex(body),
+	).Replace(s.template)
+
+	mac := hmac.New(s.algorithm, []byte(s.secret))
+	mac.Write([]byte(message))
+
+	signature := hex.EncodeToString(mac.Sum(nil))
+	if s.encoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	request.Header.Set(s.header, s.prefix+signature)
+	if s.timestampHeader != "" {
+		request.Header.Set(s.timestampHeader, timestamp)
+	}
+
+	return nil
+}
+
+// requestBody returns the body of request, read from a copy so that the body
+// itself is left for the attempt.
+func requestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if request.GetBody == nil {
+		return nil, errBodyNotReplayable
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
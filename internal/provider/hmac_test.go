@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestHMAC_RetriesAreResigned(t *testing.T) {
+	var timestamps []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Timestamp")
+		timestamps = append(timestamps, timestamp)
+
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(r.Method + "\n" + r.URL.Path + "\n" + timestamp + "\n" + string(body)))
+		if r.Header.Get("X-Signature") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if len(timestamps) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url": server.URL,
+		"hmac_signature": []interface{}{map[string]interface{}{
+			"secret":           "s3cret",
+			"timestamp_header": "X-Timestamp",
+		}},
+		"retry_on_status":  []interface{}{"503"},
+		"initial_interval": 1,
+		"max_interval":     1,
+	})
+
+	opts := testApiClient(t, nil).backoffOptions(d)
+	if len(opts.Auth) != 1 {
+		t.Fatalf("expected the signer alone, got %d authenticators", len(opts.Auth))
+	}
+
+	// Every attempt is signed one second later than the previous one.
+	now := time.Unix(1700000000, 0)
+	opts.Auth[0].(*hmacSigner).now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/hooks/deploy", strings.NewReader(`{"ref": "main"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), http.DefaultClient, request, opts)
+	if errSummary != "" {
+		t.Fatalf("%s: %s", errSummary, errDesc)
+	}
+	drainBody(response)
+
+	expected := []string{"1700000001", "1700000002", "1700000003"}
+	if strings.Join(timestamps, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected the timestamps %v, got %v", expected, timestamps)
+	}
+}
+
+func TestHMAC_Template(t *testing.T) {
+	request, err := http.NewRequest(http.MethodPut, "https://hooks.example.com/v1/events?id=42", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &hmacSigner{
+		algorithm: sha512.New,
+		secret:    "s3cret",
+		header:    "X-Hub-Signature",
+		template:  "{timestamp}.{host}.{query}.{body_sha256}",
+		encoding:  "base64",
+		prefix:    "sha512=",
+		now:       func() time.Time { return time.Unix(1700000000, 0) },
+	}
+	if err := s.authenticate(request); err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha512.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000.hooks.example.com.id=42." + sha256Hex([]byte("payload"))))
+	expected := "sha512=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if got := request.Header.Get("X-Hub-Signature"); got != expected {
+		t.Errorf("expected X-Hub-Signature %q, got %q", expected, got)
+	}
+
+	// The body is left for the attempt.
+	if body, _ := io.ReadAll(request.Body); string(body) != "payload" {
+		t.Errorf("expected the request body to be left unread, got %q", body)
+	}
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeJSON decodes body keeping numbers as json.Number, so that large
+// integers such as IDs are not rounded through float64.
+func decodeJSON(body []byte) (interface{}, error) {
+	var document interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// jsonPathLookup evaluates a JSONPath expression against a decoded JSON
+// document. The supported subset covers the root ($), child members (.name or
+// ['name']) and array indices ([0]), which is enough to pick a single value
+// out of a REST or discovery document. The leading $ may be omitted.
+func jsonPathLookup(document interface{}, path string) (interface{}, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%s: no member %q", path, token)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not an array index", path, token)
+			}
+			if index < 0 {
+				index += len(node)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("%s: index %s is out of range", path, token)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("%s: cannot select %q from a scalar value", path, token)
+		}
+	}
+
+	return current, nil
+}
+
+func parseJSONPath(path string) ([]string, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p
+	}
+
+	var tokens []string
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end == -1 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			}
+			tokens = append(tokens, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated bracket", path)
+			}
+			token := strings.TrimSpace(p[1:end])
+			if len(token) >= 2 && (token[0] == '\'' || token[0] == '"') && token[len(token)-1] == token[0] {
+				token = token[1 : len(token)-1]
+			}
+			tokens = append(tokens, token)
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q", path)
+		}
+	}
+
+	return tokens, nil
+}
+
+// jsonValueString renders a value selected from a JSON document as a
+// string: scalars are rendered as-is and objects or arrays as JSON.
+func jsonValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestJSONPathLookup(t *testing.T) {
+	document, err := decodeJSON([]byte(`{
+		"id": 12345678901234567890,
+		"status": "UP",
+		"data": {"name": "api", "tags": ["a", "b"], "ready": true},
+		"dotted.key": "x"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path     string
+		expected string
+		err      bool
+	}{
+		{path: "$.id", expected: "12345678901234567890"},
+		{path: "id", expected: "12345678901234567890"},
+		{path: "$.status", expected: "UP"},
+		{path: "data.name", expected: "api"},
+		{path: "$.data.tags[1]", expected: "b"},
+		{path: "$.data.tags[-1]", expected: "b"},
+		{path: "$['data']['ready']", expected: "true"},
+		{path: `$["dotted.key"]`, expected: "x"},
+		{path: "$.data.tags", expected: `["a","b"]`},
+		{path: "$", expected: ""},
+		{path: "$.missing", err: true},
+		{path: "$.data.tags[2]", err: true},
+		{path: "$.status.value", err: true},
+		{path: "$.data[", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			value, err := jsonPathLookup(document, tc.path)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.path == "$" {
+				if _, ok := value.(map[string]interface{}); !ok {
+					t.Errorf("expected the root object, got %T", value)
+				}
+				return
+			}
+
+			got, err := jsonValueString(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oauth2Schema returns the block that obtains bearer tokens through the
+// OAuth2 client credentials grant.
+func oauth2Schema(conflictsWith ...string) *schema.Schema {
+	return &schema.Schema{
+		Description: "Obtains access tokens from an OAuth2 authorization server with the client credentials grant, " +
+			"and sends them as bearer tokens. Tokens are cached and renewed when they expire, including between retries.",
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: conflictsWith,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"token_url": {
+					Description: "The token endpoint of the authorization server.",
+					Type:        schema.TypeString,
+					Required:    true,
+				},
+
+				"client_id": {
+					Description: "The client ID.",
+					Type:        schema.TypeString,
+					Required:    true,
+				},
+
+				"client_secret": {
+					Description: "The client secret.",
+					Type:        schema.TypeString,
+					Required:    true,
+					Sensitive:   true,
+				},
+
+				"scopes": {
+					Description: "The scopes to request.",
+					Type:        schema.TypeList,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+					Optional:    true,
+				},
+
+				"audience": {
+					Description: "The audience of the token, sent as the `audience` parameter that some authorization servers require.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"endpoint_params": {
+					Description: "Additional parameters sent to the token endpoint.",
+					Type:        schema.TypeMap,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+					Optional:    true,
+				},
+			},
+		},
+	}
+}
+
+// oauth2Config is the expanded form of an oauth2 block.
+type oauth2Config struct {
+	TokenURL       string            `json:"token_url"`
+	ClientID       string            `json:"client_id"`
+	ClientSecret   string            `json:"client_secret"`
+	Scopes         []string          `json:"scopes"`
+	Audience       string            `json:"audience"`
+	EndpointParams map[string]string `json:"endpoint_params"`
+}
+
+func expandOAuth2(d *schema.ResourceData) *oauth2Config {
+	block := firstBlock(d.Get("oauth2"))
+	if block == nil {
+		return nil
+	}
+
+	c := &oauth2Config{
+		TokenURL:       block["token_url"].(string),
+		ClientID:       block["client_id"].(string),
+		ClientSecret:   block["client_secret"].(string),
+		Scopes:         expandStringList(block["scopes"].([]interface{})),
+		Audience:       block["audience"].(string),
+		EndpointParams: map[string]string{},
+	}
+
+	for k, v := range block["endpoint_params"].(map[string]interface{}) {
+		c.EndpointParams[k] = v.(string)
+	}
+
+	return c
+}
+
+// key identifies the tokens obtained with c.
+func (c *oauth2Config) key() string {
+	scopes := append([]string(nil), c.Scopes...)
+	sort.Strings(scopes)
+
+	normalized := *c
+	normalized.Scopes = scopes
+
+	encoded, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenSource returns a source of tokens for c that fetches them with client
+// and reuses them until they expire.
+func (c *oauth2Config) tokenSource(client *http.Client) oauth2.TokenSource {
+	params := url.Values{}
+	for k, v := range c.EndpointParams {
+		params.Set(k, v)
+	}
+	if c.Audience != "" {
+		params.Set("audience", c.Audience)
+	}
+
+	config := &clientcredentials.Config{
+		ClientID:       c.ClientID,
+		ClientSecret:   c.ClientSecret,
+		TokenURL:       c.TokenURL,
+		Scopes:         c.Scopes,
+		EndpointParams: params,
+	}
+
+	// The token source outlives the request that first uses it, so it is
+	// not tied to the context of that request.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+
+	return config.TokenSource(ctx)
+}
+
+// tokenSources caches token sources by configuration, so that data sources
+// and resources sharing a configuration share their tokens too.
+type tokenSources struct {
+	mu      sync.Mutex
+	sources map[string]oauth2.TokenSource
+}
+
+func (s *tokenSources) get(c *oauth2Config, client *http.Client) oauth2.TokenSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := c.key()
+	if source, ok := s.sources[key]; ok {
+		return source
+	}
+
+	if s.sources == nil {
+		s.sources = map[string]oauth2.TokenSource{}
+	}
+
+	source := c.tokenSource(client)
+	s.sources[key] = source
+
+	return source
+}
+
+// oauth2Auth sets a token of its source on every attempt. The source only
+// contacts the authorization server when its token has expired, so a token
+// that expires during a long backoff wait is renewed for the next attempt.
+type oauth2Auth struct {
+	source oauth2.TokenSource
+}
+
+func (a oauth2Auth) authenticate(request *http.Request) error {
+	token, err := a.source.Token()
+	if err != nil {
+		return err
+	}
+
+	token.SetAuthHeader(request)
+
+	return nil
+}
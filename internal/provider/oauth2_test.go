@@ -0,0 +1,216 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// tokenEndpoint is a stand-in OAuth2 authorization server that issues
+// numbered tokens valid for expiresIn seconds.
+type tokenEndpoint struct {
+	expiresIn int
+
+	mu     sync.Mutex
+	issued int
+	forms  []map[string]string
+}
+
+func (e *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" || clientID != "terraform" || clientSecret != "s3cret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid_client"}`))
+		return
+	}
+
+	form := map[string]string{}
+	for k := range r.PostForm {
+		form[k] = r.PostForm.Get(k)
+	}
+	e.forms = append(e.forms, form)
+
+	e.issued++
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": fmt.Sprintf("token-%d", e.issued),
+		"token_type":   "Bearer",
+		"expires_in":   e.expiresIn,
+	})
+}
+
+func (e *tokenEndpoint) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.issued
+}
+
+func testOAuth2Block(tokenURL string) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"token_url":       tokenURL,
+		"client_id":       "terraform",
+		"client_secret":   "s3cret",
+		"scopes":          []interface{}{"status:read", "health:read"},
+		"audience":        "https://api.example.com",
+		"endpoint_params": map[string]interface{}{"resource": "status"},
+	}}
+}
+
+func TestOAuth2_TokensAreCached(t *testing.T) {
+	tokens := &tokenEndpoint{expiresIn: 3600}
+	tokenServer := httptest.NewServer(tokens)
+	defer tokenServer.Close()
+
+	var authorizations []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	c := testApiClient(t, map[string]interface{}{"oauth2": testOAuth2Block(tokenServer.URL)})
+
+	for i := 0; i < 3; i++ {
+		d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": api.URL})
+
+		request, err := http.NewRequest(http.MethodGet, api.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), c.client, request, c.backoffOptions(d))
+		if errSummary != "" {
+			t.Fatalf("%s: %s", errSummary, errDesc)
+		}
+		drainBody(response)
+	}
+
+	if tokens.count() != 1 {
+		t.Errorf("expected a single token to be issued for the same configuration, got %d", tokens.count())
+	}
+
+	for _, authorization := range authorizations {
+		if authorization != "Bearer token-1" {
+			t.Errorf("expected the cached token to be sent, got %q", authorization)
+		}
+	}
+
+	expected := map[string]string{
+		"grant_type": "client_credentials",
+		"scope":      "status:read health:read",
+		"audience":   "https://api.example.com",
+		"resource":   "status",
+	}
+	for k, v := range expected {
+		if got := tokens.forms[0][k]; got != v {
+			t.Errorf("expected the token request to have %s=%q, got %q", k, v, got)
+		}
+	}
+}
+
+func TestOAuth2_TokenIsRefreshedBetweenAttempts(t *testing.T) {
+	// golang.org/x/oauth2 renews tokens 10 seconds before they expire, so
+	// these tokens have to be renewed once they are a second old.
+	tokens := &tokenEndpoint{expiresIn: 11}
+	tokenServer := httptest.NewServer(tokens)
+	defer tokenServer.Close()
+
+	var authorizations []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if len(authorizations) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer api.Close()
+
+	c := testApiClient(t, map[string]interface{}{})
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":                  api.URL,
+		"oauth2":               testOAuth2Block(tokenServer.URL),
+		"initial_interval":     1500,
+		"randomization_factor": "0",
+		"retry_on_status":      []interface{}{"503"},
+	})
+
+	request, err := http.NewRequest(http.MethodGet, api.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), c.client, request, c.backoffOptions(d))
+	if errSummary != "" {
+		t.Fatalf("%s: %s", errSummary, errDesc)
+	}
+	drainBody(response)
+
+	if len(authorizations) != 2 || authorizations[0] != "Bearer token-1" || authorizations[1] != "Bearer token-2" {
+		t.Errorf("expected the token to be renewed for the retry, got %q", authorizations)
+	}
+}
+
+func TestOAuth2_Precedence(t *testing.T) {
+	c := testApiClient(t, map[string]interface{}{"oauth2": testOAuth2Block("https://auth.example.com/token")})
+
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com"})
+	if opts := c.backoffOptions(d); len(opts.Auth) != 1 {
+		t.Errorf("expected the oauth2 block of the provider to be used, got %d authenticators", len(opts.Auth))
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":  "https://example.com",
+		"auth": []interface{}{map[string]interface{}{"bearer": []interface{}{map[string]interface{}{"token": "t0ken"}}}},
+	})
+	opts := c.backoffOptions(d)
+	if len(opts.Auth) != 1 {
+		t.Fatalf("expected the auth block to replace the oauth2 block of the provider, got %d authenticators", len(opts.Auth))
+	}
+	if _, ok := opts.Auth[0].(bearerAuth); !ok {
+		t.Errorf("expected the auth block to be used, got %T", opts.Auth[0])
+	}
+}
+
+func TestOAuth2_InvalidClient(t *testing.T) {
+	tokenServer := httptest.NewServer(&tokenEndpoint{expiresIn: 3600})
+	defer tokenServer.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer api.Close()
+
+	block := testOAuth2Block(tokenServer.URL)
+	block[0].(map[string]interface{})["client_secret"] = "wrong"
+
+	c := testApiClient(t, map[string]interface{}{})
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":              api.URL,
+		"oauth2":           block,
+		"initial_interval": 10,
+		"max_elapsed_time": 1,
+	})
+
+	request, err := http.NewRequest(http.MethodGet, api.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, errSummary, _ := makeExponentialBackoffRequest(context.Background(), c.client, request, c.backoffOptions(d)); errSummary == "" {
+		t.Error("expected an error when no token can be obtained")
+	}
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// pinMismatchError is returned by the TLS handshake when the server
+// certificate matches none of the configured pins. It is not retried.
+type pinMismatchError struct {
+	CertSHA256 string
+	SPKISHA256 string
+}
+
+func (e *pinMismatchError) Error() string {
+	return fmt.Sprintf("certificate pin mismatch: observed certificate SHA-256 %s and SPKI SHA-256 %s", e.CertSHA256, e.SPKISHA256)
+}
+
+// verifyPins returns a tls.Config VerifyPeerCertificate hook that accepts the
+// leaf certificate only when its fingerprint or the fingerprint of its public
+// key is one of the given pins. It runs after the usual chain verification.
+func verifyPins(certPins, spkiPins []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("certificate pin mismatch: the server presented no certificate")
+		}
+
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("error parsing the server certificate: %w", err)
+		}
+
+		certSum := sha256.Sum256(leaf.Raw)
+		spkiSum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+		certFingerprint := hex.EncodeToString(certSum[:])
+		spkiFingerprint := hex.EncodeToString(spkiSum[:])
+
+		for _, pin := range certPins {
+			if pin == certFingerprint {
+				return nil
+			}
+		}
+
+		for _, pin := range spkiPins {
+			if pin == spkiFingerprint {
+				return nil
+			}
+		}
+
+		return &pinMismatchError{CertSHA256: certFingerprint, SPKISHA256: spkiFingerprint}
+	}
+}
+
+// normalizePin returns pin as a lowercase hex string. Pins may be given in
+// hex, optionally colon separated as printed by openssl, or in base64.
+func normalizePin(pin string) (string, error) {
+	hexPin := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+	if decoded, err := hex.DecodeString(hexPin); err == nil && len(decoded) == sha256.Size {
+		return hexPin, nil
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pin)); err == nil && len(decoded) == sha256.Size {
+		return hex.EncodeToString(decoded), nil
+	}
+
+	return "", fmt.Errorf("%q is not a hex or base64 encoded SHA-256 fingerprint", pin)
+}
+
+func expandPins(list []interface{}) []string {
+	var pins []string
+	for _, v := range list {
+		// Invalid pins are rejected by validatePin before this point.
+		if pin, err := normalizePin(v.(string)); err == nil {
+			pins = append(pins, pin)
+		}
+	}
+
+	return pins
+}
+
+func validatePin(v interface{}, k string) ([]string, []error) {
+	value, ok := v.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if _, err := normalizePin(value); err != nil {
+		return nil, []error{fmt.Errorf("%s: %w", k, err)}
+	}
+
+	return nil, nil
+}
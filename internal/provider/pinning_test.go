@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePin(t *testing.T) {
+	sum := sha256.Sum256([]byte("pin"))
+	expected := hex.EncodeToString(sum[:])
+
+	colons := make([]string, 0, len(sum))
+	for _, b := range sum {
+		colons = append(colons, strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+
+	for _, pin := range []string{expected, strings.Join(colons, ":"), base64.StdEncoding.EncodeToString(sum[:])} {
+		got, err := normalizePin(pin)
+		if err != nil {
+			t.Errorf("normalizePin(%q): %s", pin, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("normalizePin(%q) = %q, expected %q", pin, got, expected)
+		}
+	}
+
+	for _, pin := range []string{"", "abc", hex.EncodeToString(sum[:16])} {
+		if _, err := normalizePin(pin); err == nil {
+			t.Errorf("expected an error for %q", pin)
+		}
+	}
+}
+
+func TestCertificatePinning(t *testing.T) {
+	attempts := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}))
+	defer server.Close()
+
+	certSum := sha256.Sum256(server.Certificate().Raw)
+	spkiSum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	otherSum := sha256.Sum256([]byte("another certificate"))
+
+	testCases := []struct {
+		name     string
+		config   transportConfig
+		mismatch bool
+	}{
+		{name: "certificate pin", config: transportConfig{PinnedCertSHA256: []string{hex.EncodeToString(certSum[:])}}},
+		{name: "spki pin", config: transportConfig{PinnedSPKISHA256: []string{hex.EncodeToString(spkiSum[:])}}},
+		{name: "one of several pins", config: transportConfig{PinnedCertSHA256: []string{hex.EncodeToString(otherSum[:]), hex.EncodeToString(certSum[:])}}},
+		{name: "mismatch", config: transportConfig{PinnedCertSHA256: []string{hex.EncodeToString(otherSum[:])}}, mismatch: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.CACertPEM = testServerCertPEM(server)
+			client, err := newHTTPClient(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			attempts = 0
+			_, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), client, request, backoffOptions{
+				InitialInterval: 10,
+				MaxElapsedTime:  2,
+			})
+
+			if !tc.mismatch {
+				if errSummary != "" {
+					t.Fatalf("expected the pin to match, got %s: %s", errSummary, errDesc)
+				}
+				return
+			}
+
+			if errSummary != "Certificate pin mismatch" {
+				t.Fatalf("expected a pin mismatch, got %q: %s", errSummary, errDesc)
+			}
+
+			if !strings.Contains(errDesc, hex.EncodeToString(certSum[:])) || !strings.Contains(errDesc, hex.EncodeToString(spkiSum[:])) {
+				t.Errorf("expected the observed fingerprints in %q", errDesc)
+			}
+
+			if attempts != 0 {
+				t.Errorf("expected the handshake to fail before any request, got %d requests", attempts)
+			}
+		})
+	}
+}
@@ -2,6 +2,13 @@ package provider
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -10,6 +17,26 @@ import (
 func New(version string) func() *schema.Provider {
 	return func() *schema.Provider {
 		p := &schema.Provider{
+			Schema: mergeSchemas(map[string]*schema.Schema{
+				"base_url": {
+					Description: "A base URL that relative `url` values of data sources and resources are appended to.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"default_headers": {
+					Description: "A map of request header field names and values sent with every request. " +
+						"Headers set on a data source or resource take precedence.",
+					Type:     schema.TypeMap,
+					Elem:     schema.TypeString,
+					Optional: true,
+				},
+
+				"cache": cacheSchema(),
+
+				"oauth2": oauth2Schema(),
+			}, providerBackoffSchema(), transportSchema()),
+
 			DataSourcesMap: map[string]*schema.Resource{
 				"http-wait": dataSourceScaffolding(),
 			},
@@ -24,11 +51,232 @@ func New(version string) func() *schema.Provider {
 	}
 }
 
+// providerBackoffSchema returns the backoff attributes that can be set on the
+// provider as defaults for every data source and resource.
+func providerBackoffSchema() map[string]*schema.Schema {
+	all := backoffSchema()
+	defaults := map[string]*schema.Schema{}
+	for _, k := range []string{"initial_interval", "max_elapsed_time", "randomization_factor", "multiplier", "max_interval", "retry_on_status"} {
+		defaults[k] = all[k]
+	}
+
+	return defaults
+}
+
 type apiClient struct {
+	// client is shared by every data source and resource that does not
+	// override the transport settings of the provider.
+	client *http.Client
+
+	transport      transportConfig
+	cache          *responseCache
+	baseURL        string
+	defaultHeaders map[string]interface{}
+	backoff        backoffOptions
+
+	// oauth2 is the oauth2 block of the provider, used by the data sources
+	// and resources that do not set credentials of their own.
+	oauth2 *oauth2Config
+	tokens tokenSources
+
+	// clients are the clients of the data sources and resources that do
+	// override transport settings, by settings.
+	clientsMu sync.Mutex
+	clients   map[string]*http.Client
 }
 
 func configure(version string, p *schema.Provider) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-	return func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		return &apiClient{}, nil
+	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		c := &apiClient{
+			transport:      expandTransportConfig(d),
+			cache:          expandResponseCache(d),
+			oauth2:         expandOAuth2(d),
+			baseURL:        d.Get("base_url").(string),
+			defaultHeaders: d.Get("default_headers").(map[string]interface{}),
+			backoff: backoffOptions{
+				InitialInterval:     int64(d.Get("initial_interval").(int)),
+				MaxElapsedTime:      int64(d.Get("max_elapsed_time").(int)),
+				MaxInterval:         int64(d.Get("max_interval").(int)),
+				RandomizationFactor: d.Get("randomization_factor").(string),
+				Multiplier:          d.Get("multiplier").(string),
+				RetryOnStatus:       expandStringList(d.Get("retry_on_status").([]interface{})),
+			},
+		}
+
+		client, err := c.newHTTPClient(c.transport)
+		if err != nil {
+			return nil, diag.Diagnostics{{Summary: "Error configuring the HTTP client", Detail: err.Error()}}
+		}
+		c.client = client
+
+		return c, nil
+	}
+}
+
+// httpClient returns the client to use for the data source or resource d,
+// which is the shared client unless d overrides some transport settings.
+// Clients are built once per settings, so that their connections are reused
+// rather than left open by every operation.
+func (c *apiClient) httpClient(d *schema.ResourceData) (*http.Client, error) {
+	override := expandTransportConfig(d)
+	if override.isZero() {
+		return c.client, nil
+	}
+
+	transport := c.transport.merge(override)
+	key := transport.key()
+
+	c.clientsMu.Lock()
+	defer c.clientsMu.Unlock()
+
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := c.newHTTPClient(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.clients == nil {
+		c.clients = map[string]*http.Client{}
+	}
+	c.clients[key] = client
+
+	return client, nil
+}
+
+// newHTTPClient builds a client from transport that goes through the
+// response cache of the provider, if any.
+func (c *apiClient) newHTTPClient(transport transportConfig) (*http.Client, error) {
+	client, err := newHTTPClient(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Transport = c.cache.wrap(client.Transport)
+
+	return client, nil
+}
+
+// resolveURL appends a relative URL to the base URL of the provider.
+// Absolute URLs are returned as they are.
+func (c *apiClient) resolveURL(rawURL string) string {
+	if c.baseURL == "" {
+		return rawURL
+	}
+
+	if u, err := url.Parse(rawURL); err == nil && u.IsAbs() {
+		return rawURL
+	}
+
+	if rawURL == "" {
+		return c.baseURL
+	}
+
+	return strings.TrimRight(c.baseURL, "/") + "/" + strings.TrimLeft(rawURL, "/")
+}
+
+// setDefaultHeaders sets the default headers of the provider on request,
+// unless request already has a value for them.
+func (c *apiClient) setDefaultHeaders(request *http.Request) {
+	for name, value := range c.defaultHeaders {
+		if request.Header.Get(name) == "" {
+			request.Header.Set(name, value.(string))
+		}
+	}
+}
+
+// backoffOptions returns the backoff settings of d, falling back to the
+// defaults of the provider for the settings that are not set.
+func (c *apiClient) backoffOptions(d *schema.ResourceData) backoffOptions {
+	opts := backoffOptionsFromResourceData(d)
+
+	if opts.InitialInterval == 0 {
+		opts.InitialInterval = c.backoff.InitialInterval
+	}
+
+	if opts.MaxElapsedTime == 0 {
+		opts.MaxElapsedTime = c.backoff.MaxElapsedTime
+	}
+
+	if opts.MaxInterval == 0 {
+		opts.MaxInterval = c.backoff.MaxInterval
+	}
+
+	if opts.RandomizationFactor == "" {
+		opts.RandomizationFactor = c.backoff.RandomizationFactor
+	}
+
+	if opts.Multiplier == "" {
+		opts.Multiplier = c.backoff.Multiplier
+	}
+
+	if len(opts.RetryOnStatus) == 0 {
+		opts.RetryOnStatus = c.backoff.RetryOnStatus
+	}
+
+	signer := expandSigV4(d)
+
+	config := expandOAuth2(d)
+	if config == nil && len(opts.Auth) == 0 && signer == nil {
+		config = c.oauth2
+	}
+	if config != nil {
+		opts.Auth = append(opts.Auth, c.oauth2Auth(config))
 	}
+
+	if hmac := expandHMAC(d); hmac != nil {
+		opts.Auth = append(opts.Auth, hmac)
+	}
+
+	// The signature covers the headers set by the other authenticators, so
+	// it is computed last.
+	if signer != nil {
+		opts.Auth = append(opts.Auth, signer)
+	}
+
+	opts.CredentialsID = credentialsID(d, config)
+
+	return opts
+}
+
+// credentialsID identifies the credentials configured for d, along with the
+// oauth2 settings in use, which may be those of the provider.
+func credentialsID(d *schema.ResourceData, oauth2 *oauth2Config) string {
+	config, err := json.Marshal([]interface{}{d.Get("auth"), oauth2, d.Get("aws_sigv4"), d.Get("hmac_signature")})
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:])
+}
+
+// oauth2Auth returns an authenticator for config whose tokens are shared with
+// every data source and resource using the same configuration.
+func (c *apiClient) oauth2Auth(config *oauth2Config) authenticator {
+	return oauth2Auth{source: c.tokens.get(config, c.client)}
+}
+
+// mergeSchemas returns a single schema map holding the attributes of all the
+// given maps. Later maps take precedence over earlier ones.
+func mergeSchemas(schemas ...map[string]*schema.Schema) map[string]*schema.Schema {
+	merged := map[string]*schema.Schema{}
+	for _, s := range schemas {
+		for k, v := range s {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+func expandStringList(list []interface{}) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		result = append(result, v.(string))
+	}
+
+	return result
 }
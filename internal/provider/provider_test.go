@@ -1,6 +1,9 @@
 package provider
 
 import (
+	"context"
+	"testing"
+
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -10,3 +13,98 @@ var providerFactories = map[string]func() (*schema.Provider, error){
 		return New("dev")(), nil
 	},
 }
+
+func TestProvider(t *testing.T) {
+	if err := New("dev")().InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testApiClient configures the provider with raw and returns its client.
+func testApiClient(t *testing.T, raw map[string]interface{}) *apiClient {
+	t.Helper()
+
+	p := New("dev")()
+	d := schema.TestResourceDataRaw(t, p.Schema, raw)
+
+	meta, diags := p.ConfigureContextFunc(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("error configuring provider: %v", diags)
+	}
+
+	return meta.(*apiClient)
+}
+
+func TestApiClientResolveURL(t *testing.T) {
+	testCases := []struct {
+		baseURL  string
+		url      string
+		expected string
+	}{
+		{baseURL: "", url: "https://example.com/health", expected: "https://example.com/health"},
+		{baseURL: "https://api.example.com", url: "health", expected: "https://api.example.com/health"},
+		{baseURL: "https://api.example.com/v1/", url: "/health", expected: "https://api.example.com/v1/health"},
+		{baseURL: "https://api.example.com", url: "https://other.example.com/", expected: "https://other.example.com/"},
+	}
+
+	for _, tc := range testCases {
+		c := testApiClient(t, map[string]interface{}{"base_url": tc.baseURL})
+		if got := c.resolveURL(tc.url); got != tc.expected {
+			t.Errorf("resolveURL(%q) with base %q = %q, expected %q", tc.url, tc.baseURL, got, tc.expected)
+		}
+	}
+}
+
+func TestApiClientBackoffOptions(t *testing.T) {
+	c := testApiClient(t, map[string]interface{}{
+		"max_elapsed_time": 120,
+		"initial_interval": 250,
+		"retry_on_status":  []interface{}{"5xx"},
+	})
+
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":              "https://example.com",
+		"max_elapsed_time": 10,
+	})
+
+	opts := c.backoffOptions(d)
+	if opts.MaxElapsedTime != 10 {
+		t.Errorf("expected the data source max_elapsed_time to win, got %d", opts.MaxElapsedTime)
+	}
+	if opts.InitialInterval != 250 {
+		t.Errorf("expected the provider initial_interval to be inherited, got %d", opts.InitialInterval)
+	}
+	if len(opts.RetryOnStatus) != 1 || opts.RetryOnStatus[0] != "5xx" {
+		t.Errorf("expected the provider retry_on_status to be inherited, got %v", opts.RetryOnStatus)
+	}
+}
+
+func TestApiClientHTTPClient(t *testing.T) {
+	c := testApiClient(t, map[string]interface{}{"attempt_timeout": 5000})
+
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com"})
+	client, err := c.httpClient(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != c.client {
+		t.Error("expected the shared client when nothing is overridden")
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com", "attempt_timeout": 100})
+	client, err = c.httpClient(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client == c.client || client.Timeout.Milliseconds() != 100 {
+		t.Errorf("expected a dedicated client with a 100ms timeout, got %s", client.Timeout)
+	}
+
+	same, err := c.httpClient(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != client {
+		t.Error("expected the dedicated client to be reused for the same settings")
+	}
+}
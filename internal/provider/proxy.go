@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxySettings choose the proxy of each request and connect to it.
+type proxySettings struct {
+	forURL             func(*url.URL) (*url.URL, error)
+	username, password string
+	headers            http.Header
+
+	// tlsProxies holds the TLS configuration of the https proxies, by
+	// address.
+	tlsProxies map[string]*tls.Config
+}
+
+// proxy returns the proxy settings of c. The proxy_url and no_proxy of c take
+// precedence over the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables. As with the environment variables, requests to localhost and
+// loopback addresses never go through a proxy.
+func (c transportConfig) proxy() (*proxySettings, error) {
+	config := httpproxy.FromEnvironment()
+	if c.ProxyURL != "" {
+		config.HTTPProxy = c.ProxyURL
+		config.HTTPSProxy = c.ProxyURL
+	}
+	if len(c.NoProxy) > 0 {
+		config.NoProxy = strings.Join(c.NoProxy, ",")
+	}
+
+	p := &proxySettings{
+		forURL:     config.ProxyFunc(),
+		username:   c.ProxyUsername,
+		password:   c.ProxyPassword,
+		tlsProxies: map[string]*tls.Config{},
+	}
+
+	if len(c.ProxyHeaders) > 0 {
+		p.headers = http.Header{}
+		for name, value := range c.ProxyHeaders {
+			p.headers.Set(name, value)
+		}
+	}
+
+	for _, raw := range []string{config.HTTPProxy, config.HTTPSProxy} {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "https" {
+			continue
+		}
+
+		tlsConfig, err := c.proxyTLSConfig(u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		p.tlsProxies[proxyAddress(u)] = tlsConfig
+	}
+
+	return p, nil
+}
+
+// proxyTLSConfig builds the TLS configuration of the connections to an https
+// proxy. It is separate from the one of the servers, which are usually not
+// signed by the same CA and whose pins the proxy would not match.
+func (c transportConfig) proxyTLSConfig(serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.ProxyCACertPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM([]byte(c.ProxyCACertPEM)) {
+			return nil, fmt.Errorf("proxy_ca_cert_pem does not contain any valid PEM encoded certificate")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// forRequest returns the proxy of request, or nil when it is sent directly.
+func (p *proxySettings) forRequest(request *http.Request) (*url.URL, error) {
+	proxyURL, err := p.forURL(request.URL)
+	if err != nil || proxyURL == nil {
+		return nil, err
+	}
+
+	u := *proxyURL
+	if p.username != "" {
+		u.User = url.UserPassword(p.username, p.password)
+	}
+
+	// The TLS connection to an https proxy is made by dialContext, so the
+	// transport is told that it is an http proxy, and speaks plain HTTP
+	// over that connection.
+	if u.Scheme == "https" {
+		u.Scheme = "http"
+		u.Host = proxyAddress(proxyURL)
+	}
+
+	return &u, nil
+}
+
+// dialContext wraps dial so that the connections to https proxies go through
+// TLS.
+func (p *proxySettings) dialContext(dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	if len(p.tlsProxies) == 0 {
+		return dial
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		tlsConfig, ok := p.tlsProxies[address]
+		if err != nil || !ok {
+			return conn, err
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error connecting to proxy %s: %w", address, err)
+		}
+
+		return tlsConn, nil
+	}
+}
+
+// wrap returns transport, which only sends the proxy headers with CONNECT
+// requests, made to also send them with the http requests it proxies.
+func (p *proxySettings) wrap(transport *http.Transport) http.RoundTripper {
+	if len(p.headers) == 0 {
+		return transport
+	}
+
+	return &proxyHeaderTransport{transport: transport, proxy: p}
+}
+
+type proxyHeaderTransport struct {
+	transport *http.Transport
+	proxy     *proxySettings
+}
+
+func (t *proxyHeaderTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme == "http" {
+		if proxyURL, err := t.proxy.forRequest(request); err == nil && proxyURL != nil && proxyURL.Scheme == "http" {
+			request = request.Clone(request.Context())
+			for name, values := range t.proxy.headers {
+				request.Header[name] = values
+			}
+		}
+	}
+
+	return t.transport.RoundTrip(request)
+}
+
+// proxyAddress returns the host and port of a proxy URL, with the default port
+// of its scheme when it has none.
+func proxyAddress(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	return net.JoinHostPort(u.Hostname(), "80")
+}
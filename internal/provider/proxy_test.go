@@ -0,0 +1,331 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testProxy is a stand-in HTTP proxy that forwards http requests itself and
+// tunnels every CONNECT request to backend.
+type testProxy struct {
+	backend string
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (p *testProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests = append(p.requests, r)
+	p.mu.Unlock()
+
+	if r.Method != http.MethodConnect {
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+		return
+	}
+
+	upstream, err := net.Dial("tcp", p.backend)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	conn, buffered, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	go func() {
+		_, _ = io.Copy(upstream, buffered)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func (p *testProxy) lastRequest() *http.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.requests) == 0 {
+		return nil
+	}
+
+	return p.requests[len(p.requests)-1]
+}
+
+func testProxyAuthorization(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestProxy_HTTP(t *testing.T) {
+	proxy := &testProxy{}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	client, err := newHTTPClient(transportConfig{
+		ProxyURL:      server.URL,
+		ProxyHeaders:  map[string]string{"X-Proxy-Team": "ci"},
+		ProxyUsername: "runner",
+		ProxyPassword: "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.Get("http://staging.internal/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	if string(body) != "proxied http://staging.internal/status" {
+		t.Errorf("expected the request to go through the proxy, got %q", body)
+	}
+
+	request := proxy.lastRequest()
+	if got := request.Header.Get("Proxy-Authorization"); got != testProxyAuthorization("runner", "s3cret") {
+		t.Errorf("expected the proxy credentials to be sent, got %q", got)
+	}
+	if got := request.Header.Get("X-Proxy-Team"); got != "ci" {
+		t.Errorf("expected the proxy headers to be sent, got %q", got)
+	}
+}
+
+func TestProxy_HTTPSConnect(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tunneled"))
+	}))
+	defer backend.Close()
+
+	proxy := &testProxy{backend: backend.Listener.Addr().String()}
+	server := httptest.NewTLSServer(proxy)
+	defer server.Close()
+
+	c := transportConfig{
+		CACertPEM:      testServerCertPEM(backend),
+		ProxyURL:       server.URL,
+		ProxyHeaders:   map[string]string{"X-Proxy-Team": "ci"},
+		ProxyUsername:  "runner",
+		ProxyPassword:  "s3cret",
+		ProxyCACertPEM: testServerCertPEM(server),
+	}
+
+	// The certificate of httptest servers is valid for example.com.
+	if err := testGet(t, c, "https://example.com/status"); err != nil {
+		t.Fatalf("expected the request to be tunneled through the proxy, got %s", err)
+	}
+
+	request := proxy.lastRequest()
+	if request.Method != http.MethodConnect || request.Host != "example.com:443" {
+		t.Errorf("expected a CONNECT request to example.com:443, got %s %s", request.Method, request.Host)
+	}
+	if got := request.Header.Get("Proxy-Authorization"); got != testProxyAuthorization("runner", "s3cret") {
+		t.Errorf("expected the proxy credentials to be sent, got %q", got)
+	}
+	if got := request.Header.Get("X-Proxy-Team"); got != "ci" {
+		t.Errorf("expected the proxy headers to be sent, got %q", got)
+	}
+
+	// The CA of the servers is not trusted for the proxy.
+	c.ProxyCACertPEM = ""
+	if err := testGet(t, c, "https://example.com/status"); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected a certificate error without proxy_ca_cert_pem, got %v", err)
+	}
+}
+
+// testSOCKS5Proxy is a stand-in SOCKS5 proxy that requires username and
+// password authentication and connects every request to backend.
+func testSOCKS5Proxy(t *testing.T, backend, username, password string) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	targets := make(chan string, 10)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				// Greeting: the username and password method is chosen.
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte{5, 2})
+
+				// Username and password authentication, RFC 1929.
+				readString := func() string {
+					length := make([]byte, 1)
+					_, _ = io.ReadFull(conn, length)
+					value := make([]byte, length[0])
+					_, _ = io.ReadFull(conn, value)
+					return string(value)
+				}
+				_, _ = io.ReadFull(conn, make([]byte, 1))
+				if readString() != username || readString() != password {
+					_, _ = conn.Write([]byte{1, 1})
+					return
+				}
+				_, _ = conn.Write([]byte{1, 0})
+
+				// Connect request to a domain name or an IPv4 address.
+				request := make([]byte, 4)
+				if _, err := io.ReadFull(conn, request); err != nil {
+					return
+				}
+				host := ""
+				switch request[3] {
+				case 1:
+					ip := make([]byte, 4)
+					_, _ = io.ReadFull(conn, ip)
+					host = net.IP(ip).String()
+				case 3:
+					host = readString()
+				}
+				port := make([]byte, 2)
+				_, _ = io.ReadFull(conn, port)
+				targets <- net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+				upstream, err := net.Dial("tcp", backend)
+				if err != nil {
+					_, _ = conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+				go func() { _, _ = io.Copy(upstream, conn) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), targets
+}
+
+func TestProxy_SOCKS5(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("through socks"))
+	}))
+	defer backend.Close()
+
+	address, targets := testSOCKS5Proxy(t, backend.Listener.Addr().String(), "runner", "s3cret")
+
+	c := transportConfig{ProxyURL: "socks5://" + address, ProxyUsername: "runner", ProxyPassword: "s3cret"}
+	if err := testGet(t, c, "http://staging.internal:8080/status"); err != nil {
+		t.Fatalf("expected the request to go through the SOCKS5 proxy, got %s", err)
+	}
+
+	if target := <-targets; target != "staging.internal:8080" {
+		t.Errorf("expected the proxy to connect to staging.internal:8080, got %s", target)
+	}
+
+	c.ProxyPassword = "wrong"
+	if err := testGet(t, c, "http://staging.internal:8080/status"); err == nil {
+		t.Error("expected an error for wrong proxy credentials")
+	}
+}
+
+func TestProxy_NoProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://env-proxy.internal:3128")
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.internal:3128")
+	t.Setenv("NO_PROXY", "staging.example.com")
+
+	testCases := []struct {
+		name     string
+		config   transportConfig
+		url      string
+		expected string
+	}{
+		{
+			name:     "environment",
+			url:      "https://api.example.com/",
+			expected: "http://env-proxy.internal:3128",
+		},
+		{
+			name:     "environment no proxy",
+			url:      "https://staging.example.com/",
+			expected: "",
+		},
+		{
+			name:     "proxy url",
+			config:   transportConfig{ProxyURL: "http://squid.internal:3128"},
+			url:      "https://api.example.com/",
+			expected: "http://squid.internal:3128",
+		},
+		{
+			name:     "no proxy replaces the environment",
+			config:   transportConfig{ProxyURL: "http://squid.internal:3128", NoProxy: []string{".prod.example.com", "10.0.0.0/8"}},
+			url:      "https://staging.example.com/",
+			expected: "http://squid.internal:3128",
+		},
+		{
+			name:     "no proxy domain",
+			config:   transportConfig{ProxyURL: "http://squid.internal:3128", NoProxy: []string{".prod.example.com", "10.0.0.0/8"}},
+			url:      "https://api.prod.example.com/",
+			expected: "",
+		},
+		{
+			name:     "no proxy range",
+			config:   transportConfig{ProxyURL: "http://squid.internal:3128", NoProxy: []string{".prod.example.com", "10.0.0.0/8"}},
+			url:      "http://10.1.2.3:8080/",
+			expected: "",
+		},
+		{
+			name:     "https proxy",
+			config:   transportConfig{ProxyURL: "https://squid.internal"},
+			url:      "https://api.example.com/",
+			expected: "http://squid.internal:443",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proxy, err := tc.config.proxy()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proxyURL, err := proxy.forRequest(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ""
+			if proxyURL != nil {
+				got = proxyURL.String()
+			}
+			if got != tc.expected {
+				t.Errorf("expected the proxy %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
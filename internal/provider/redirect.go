@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// redirectSchema returns the attributes that control how redirects are
+// followed.
+func redirectSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"follow_redirects": {
+			Description: "Whether redirects are followed. When `false`, the redirect response itself is returned. Defaults to `true`.",
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     true,
+		},
+
+		"max_redirects": {
+			Description:  "The maximum number of redirects to follow. An attempt redirected more times fails. Defaults to 10.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      10,
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+
+		"keep_auth_on_redirect": {
+			Description: "Whether credentials are still sent when a redirect leads to another host. By default, the `Authorization`, " +
+				"`Proxy-Authorization` and `Cookie` headers, and the headers set by the `auth`, `oauth2`, `aws_sigv4` and " +
+				"`hmac_signature` blocks, are only sent to the host of the URL.",
+			Type:     schema.TypeBool,
+			Optional: true,
+		},
+	}
+}
+
+// redirectPolicy controls the redirects followed by the attempts of a request.
+type redirectPolicy struct {
+	Follow   bool
+	Max      int
+	KeepAuth bool
+}
+
+func expandRedirectPolicy(d *schema.ResourceData) *redirectPolicy {
+	return &redirectPolicy{
+		Follow:   d.Get("follow_redirects").(bool),
+		Max:      d.Get("max_redirects").(int),
+		KeepAuth: d.Get("keep_auth_on_redirect").(bool),
+	}
+}
+
+// credentialHeaders are always considered credentials, along with the
+// headers the authenticators set.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// redirectLimitError is returned when an attempt is redirected more than
+// max_redirects times. It is not retried.
+type redirectLimitError struct {
+	Max int
+	URL string
+}
+
+func (e *redirectLimitError) Error() string {
+	return fmt.Sprintf("stopped after %d redirects, at %s", e.Max, e.URL)
+}
+
+// checkRedirect returns the CheckRedirect function of the client sending an
+// attempt, whose credentials are in the authHeaders headers. jar tells
+// whether the client has a cookie jar.
+func (p *redirectPolicy) checkRedirect(authHeaders []string, jar bool) func(*http.Request, []*http.Request) error {
+	return func(next *http.Request, via []*http.Request) error {
+		if !p.Follow {
+			return http.ErrUseLastResponse
+		}
+
+		if len(via) > p.Max {
+			return &redirectLimitError{Max: p.Max, URL: redactURL(next.URL)}
+		}
+
+		// The client copies the headers of the first request of the attempt,
+		// but for the credentials it drops on the way to another domain,
+		// and adds the cookies of its jar afterwards. The cookies of the
+		// first request include the ones of the jar, which may have been
+		// replaced since, so they are left to the jar.
+		first := via[0]
+		sameHost := strings.EqualFold(next.URL.Hostname(), first.URL.Hostname())
+
+		for _, name := range authHeaders {
+			switch {
+			case sameHost:
+			case p.KeepAuth:
+				if _, ok := next.Header[name]; !ok && len(first.Header[name]) > 0 && !(jar && name == "Cookie") {
+					next.Header[name] = first.Header[name]
+				}
+			default:
+				next.Header.Del(name)
+			}
+		}
+
+		return nil
+	}
+}
+
+// authHeaders returns the names of the credential headers of an attempt,
+// given the headers the authenticators set.
+func authHeaders(authenticated []string) []string {
+	return append(append([]string(nil), credentialHeaders...), authenticated...)
+}
+
+// changedHeaders returns the names of the headers of after that are not in
+// before or have other values.
+func changedHeaders(before, after http.Header) []string {
+	var names []string
+	for name, values := range after {
+		if strings.Join(values, "\n") != strings.Join(before[name], "\n") {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// redirectChain returns the URLs requested to get response, from the first
+// one to the one of response.
+func redirectChain(response *http.Response) []*url.URL {
+	var chain []*url.URL
+
+	for request := response.Request; request != nil; {
+		chain = append([]*url.URL{request.URL}, chain...)
+
+		if request.Response == nil {
+			break
+		}
+		request = request.Response.Request
+	}
+
+	return chain
+}
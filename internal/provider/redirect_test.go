@@ -0,0 +1,252 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testRedirects starts a server whose /hop/n paths redirect n times before
+// landing on /landing, and records the headers /landing receives. The
+// redirects lead to target, which defaults to the server itself.
+func testRedirects(t *testing.T, target func(server *httptest.Server) string) (*httptest.Server, chan http.Header) {
+	t.Helper()
+
+	landed := make(chan http.Header, 1)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/landing" {
+			landed <- r.Header.Clone()
+			return
+		}
+
+		base := server.URL
+		if target != nil {
+			base = target(server)
+		}
+
+		hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		next := "/landing"
+		if hops > 1 {
+			next = "/hop/" + strconv.Itoa(hops-1)
+		}
+
+		http.Redirect(w, r, base+next, http.StatusFound)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, landed
+}
+
+func testRedirectRequest(t *testing.T, url string, policy *redirectPolicy) (*http.Response, string) {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("X-Trace", "trace")
+
+	opts := backoffOptions{
+		MaxElapsedTime: 1,
+		Auth:           []authenticator{bearerAuth{token: "s3cret"}, apiKeyAuth{header: "X-API-Key", value: "key"}},
+		Redirects:      policy,
+	}
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), http.DefaultClient, request, opts)
+	if errSummary != "" {
+		return nil, errDesc
+	}
+	drainBody(response)
+
+	return response, ""
+}
+
+func TestRedirects_CredentialsAcrossHosts(t *testing.T) {
+	// The server is reached as localhost after the redirects, another host
+	// than the 127.0.0.1 of its URL.
+	server, landed := testRedirects(t, func(server *httptest.Server) string {
+		return strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	})
+
+	testCases := []struct {
+		name     string
+		keepAuth bool
+	}{
+		{"stripped", false},
+		{"kept", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, errDesc := testRedirectRequest(t, server.URL+"/hop/2", &redirectPolicy{Follow: true, Max: 10, KeepAuth: tc.keepAuth}); errDesc != "" {
+				t.Fatal(errDesc)
+			}
+
+			header := <-landed
+			for _, name := range []string{"Authorization", "X-Api-Key"} {
+				if got := header.Get(name) != ""; got != tc.keepAuth {
+					t.Errorf("expected %s to be sent to the other host: %t, got %q", name, tc.keepAuth, header.Get(name))
+				}
+			}
+			if header.Get("X-Trace") != "trace" {
+				t.Errorf("expected the other request headers to be kept, got %v", header)
+			}
+		})
+	}
+}
+
+func TestRedirects_CredentialsOnSameHost(t *testing.T) {
+	server, landed := testRedirects(t, nil)
+
+	if _, errDesc := testRedirectRequest(t, server.URL+"/hop/2", &redirectPolicy{Follow: true, Max: 10}); errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	header := <-landed
+	if header.Get("Authorization") != "Bearer s3cret" || header.Get("X-Api-Key") != "key" {
+		t.Errorf("expected the credentials to be kept on the same host, got %v", header)
+	}
+}
+
+func TestRedirects_RotatedSessionCookie(t *testing.T) {
+	landed := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rotate":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "new", Path: "/"})
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		case "/landing":
+			landed <- r.Header.Get("Cookie")
+		}
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverURL, _ := url.Parse(server.URL)
+	jar.SetCookies(serverURL, []*http.Cookie{{Name: "session", Value: "old", Path: "/"}})
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/rotate", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keepAuth := range []bool{false, true} {
+		opts := backoffOptions{MaxElapsedTime: 1, Redirects: &redirectPolicy{Follow: true, Max: 10, KeepAuth: keepAuth}}
+		response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), &http.Client{Jar: jar}, request, opts)
+		if errSummary != "" {
+			t.Fatalf("%s: %s", errSummary, errDesc)
+		}
+		drainBody(response)
+
+		if cookie := <-landed; cookie != "session=new" {
+			t.Errorf("expected only the rotated session cookie with keep_auth_on_redirect %t, got %q", keepAuth, cookie)
+		}
+	}
+}
+
+func TestRedirects_Policy(t *testing.T) {
+	server, _ := testRedirects(t, nil)
+
+	response, errDesc := testRedirectRequest(t, server.URL+"/hop/3", &redirectPolicy{Follow: false, Max: 10})
+	if errDesc != "" {
+		t.Fatal(errDesc)
+	}
+	if response.StatusCode != http.StatusFound || response.Header.Get("Location") != server.URL+"/hop/2" {
+		t.Errorf("expected the redirect itself when redirects are not followed, got %s to %s", response.Status, response.Header.Get("Location"))
+	}
+
+	if _, errDesc := testRedirectRequest(t, server.URL+"/hop/3", &redirectPolicy{Follow: true, Max: 2}); !strings.Contains(errDesc, "stopped after 2 redirects") {
+		t.Errorf("expected an error past max_redirects, got %q", errDesc)
+	}
+
+	response, errDesc = testRedirectRequest(t, server.URL+"/hop/3", &redirectPolicy{Follow: true, Max: 3})
+	if errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	var chain []string
+	for _, u := range redirectChain(response) {
+		chain = append(chain, strings.TrimPrefix(u.String(), server.URL))
+	}
+	if strings.Join(chain, " ") != "/hop/3 /hop/2 /hop/1 /landing" {
+		t.Errorf("unexpected redirect chain %v", chain)
+	}
+}
+
+func TestRedirects_LimitIsNotRetried(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer server.Close()
+
+	_, errDesc := testRedirectRequest(t, server.URL+"/loop", &redirectPolicy{Follow: true, Max: 2})
+	if !strings.Contains(errDesc, "stopped after 2 redirects") {
+		t.Errorf("expected an error past max_redirects, got %q", errDesc)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected a single attempt of 3 requests, got %d requests", n)
+	}
+}
+
+func TestRedirects_ResumeAcrossHosts(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+
+	// The release URL redirects to a CDN reached as localhost, another host
+	// than the 127.0.0.1 of the release server.
+	cdn := &resumableServer{content: content, etag: func(int) string { return `"v1"` }, dropAfter: []int{300000}}
+	cdnServer := httptest.NewServer(cdn)
+	defer cdnServer.Close()
+
+	release := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(cdnServer.URL, "127.0.0.1", "localhost", 1)+"/artifact.bin", http.StatusFound)
+	}))
+	defer release.Close()
+
+	sum := sha256.Sum256(content)
+	if errDesc := testDownload(t, release.URL, backoffOptions{
+		OutputFile:     filepath.Join(t.TempDir(), "artifact.bin"),
+		ResumeDownload: true,
+		ExpectedSHA256: hex.EncodeToString(sum[:]),
+		Auth:           []authenticator{bearerAuth{token: "s3cret"}},
+		Redirects:      &redirectPolicy{Follow: true, Max: 10},
+	}); errDesc != "" {
+		t.Fatal(errDesc)
+	}
+
+	cdn.mu.Lock()
+	defer cdn.mu.Unlock()
+
+	var ranges []string
+	for _, r := range cdn.attempts {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("expected the credentials to be dropped on the way to the CDN, got %q", r.Header.Get("Authorization"))
+		}
+	}
+
+	if strings.Join(ranges, ",") != ",bytes=300000-" {
+		t.Errorf("expected the download to be resumed from the CDN, got Range headers %q", ranges)
+	}
+
+	if cdn.served != len(content) {
+		t.Errorf("expected %d bytes to be served, got %d", len(content), cdn.served)
+	}
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+var supportedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// requestSchema returns the attributes shared by the data source and the
+// resource that describe the request to make.
+func requestSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"method": {
+			Description:  "The HTTP method of the request. One of `GET`, `HEAD`, `POST`, `PUT`, `PATCH`, `DELETE` or `OPTIONS`. Defaults to `GET`.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      http.MethodGet,
+			ValidateFunc: validation.StringInSlice(supportedMethods, false),
+		},
+
+		"request_body": {
+			Description:   "The request body as a string.",
+			Type:          schema.TypeString,
+			Optional:      true,
+			ConflictsWith: []string{"request_body_base64"},
+		},
+
+		"request_body_base64": {
+			Description:   "The request body as a base64 encoded string, for binary payloads.",
+			Type:          schema.TypeString,
+			Optional:      true,
+			ValidateFunc:  validation.StringIsBase64,
+			ConflictsWith: []string{"request_body"},
+		},
+	}
+}
+
+// newRequestFromResourceData builds the request described by the method and
+// request body attributes of d. The body is attached through a bytes.Reader
+// so that the request has a GetBody function and can be replayed on retries.
+func newRequestFromResourceData(ctx context.Context, d *schema.ResourceData, url string) (*http.Request, error) {
+	var body io.Reader
+
+	if v, ok := d.GetOk("request_body"); ok {
+		body = bytes.NewReader([]byte(v.(string)))
+	}
+
+	if v, ok := d.GetOk("request_body_base64"); ok {
+		decoded, err := base64.StdEncoding.DecodeString(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding request_body_base64: %w", err)
+		}
+		body = bytes.NewReader(decoded)
+	}
+
+	return http.NewRequestWithContext(ctx, d.Get("method").(string), url, body)
+}
+
+// cloneRequest returns a copy of request for a single attempt, with a fresh
+// body obtained from GetBody so that every retry sends the full payload.
+func cloneRequest(ctx context.Context, request *http.Request) (*http.Request, error) {
+	attempt := request.Clone(ctx)
+	if request.GetBody == nil {
+		return attempt, nil
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	attempt.Body = body
+
+	return attempt, nil
+}
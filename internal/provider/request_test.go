@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestNewRequestFromResourceData(t *testing.T) {
+	testCases := []struct {
+		name           string
+		raw            map[string]interface{}
+		expectedMethod string
+		expectedBody   string
+	}{
+		{
+			name:           "default",
+			raw:            map[string]interface{}{},
+			expectedMethod: http.MethodGet,
+		},
+		{
+			name:           "string body",
+			raw:            map[string]interface{}{"method": "POST", "request_body": `{"warm":true}`},
+			expectedMethod: http.MethodPost,
+			expectedBody:   `{"warm":true}`,
+		},
+		{
+			name:           "base64 body",
+			raw:            map[string]interface{}{"method": "PUT", "request_body_base64": "AAEC/w=="},
+			expectedMethod: http.MethodPut,
+			expectedBody:   "\x00\x01\x02\xff",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, requestSchema(), tc.raw)
+
+			request, err := newRequestFromResourceData(context.Background(), d, "http://localhost")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if request.Method != tc.expectedMethod {
+				t.Errorf("expected method %s, got %s", tc.expectedMethod, request.Method)
+			}
+
+			// Every attempt must send the full body, not only the first one.
+			for i := 0; i < 2; i++ {
+				attempt, err := cloneRequest(context.Background(), request)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var body []byte
+				if attempt.Body != nil {
+					body, _ = io.ReadAll(attempt.Body)
+				}
+
+				if string(body) != tc.expectedBody {
+					t.Errorf("attempt %d: expected body %q, got %q", i, tc.expectedBody, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// connectToRule sends the connections to host and port to toHost and toPort
+// instead. An empty field matches any host or port, or leaves it unchanged.
+type connectToRule struct {
+	host, port     string
+	toHost, toPort string
+}
+
+// addressOverrides change the addresses the transport connects to, as the
+// --connect-to and --resolve options of curl do. Only the connections are
+// affected: the URL, and so the Host header and the server name verified
+// through TLS, are left as they are.
+type addressOverrides struct {
+	connectTo []connectToRule
+
+	// resolve holds the IP address of lowercase host:port addresses.
+	resolve map[string]string
+}
+
+func (c transportConfig) addressOverrides() (*addressOverrides, error) {
+	o := &addressOverrides{resolve: map[string]string{}}
+
+	for _, raw := range c.ConnectTo {
+		rule, err := parseConnectTo(raw)
+		if err != nil {
+			return nil, err
+		}
+		o.connectTo = append(o.connectTo, rule)
+	}
+
+	for address, raw := range c.Resolve {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || host == "" || !isPort(port) {
+			return nil, fmt.Errorf("resolve key %q is not a host:port address", address)
+		}
+
+		ip := net.ParseIP(strings.Trim(raw, "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("resolve value %q of %s is not an IP address", raw, address)
+		}
+
+		o.resolve[net.JoinHostPort(strings.ToLower(host), port)] = ip.String()
+	}
+
+	return o, nil
+}
+
+// parseConnectTo parses a HOST1:PORT1:HOST2:PORT2 rule, whose IPv6 addresses
+// are enclosed in brackets.
+func parseConnectTo(raw string) (connectToRule, error) {
+	var fields []string
+
+	rest := raw
+	for len(fields) < 3 {
+		var field string
+		var ok bool
+
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 || !strings.HasPrefix(rest[end+1:], ":") {
+				return connectToRule{}, fmt.Errorf("connect_to %q has an unterminated IPv6 address", raw)
+			}
+			field, rest = rest[1:end], rest[end+2:]
+		} else if field, rest, ok = strings.Cut(rest, ":"); !ok {
+			return connectToRule{}, fmt.Errorf("connect_to %q is not of the form HOST1:PORT1:HOST2:PORT2", raw)
+		}
+
+		fields = append(fields, field)
+	}
+
+	rule := connectToRule{
+		host:   strings.ToLower(fields[0]),
+		port:   fields[1],
+		toHost: strings.Trim(fields[2], "[]"),
+		toPort: rest,
+	}
+
+	for _, port := range []string{rule.port, rule.toPort} {
+		if port != "" && !isPort(port) {
+			return connectToRule{}, fmt.Errorf("connect_to %q has an invalid port %q", raw, port)
+		}
+	}
+
+	return rule, nil
+}
+
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port < 65536
+}
+
+// address returns the address to connect to instead of address. The first
+// connect_to rule matching address applies, then the IP address the resolve
+// map has for the result, if any.
+func (o *addressOverrides) address(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	host = strings.ToLower(host)
+
+	for _, rule := range o.connectTo {
+		if (rule.host == "" || rule.host == host) && (rule.port == "" || rule.port == port) {
+			if rule.toHost != "" {
+				host = strings.ToLower(rule.toHost)
+			}
+			if rule.toPort != "" {
+				port = rule.toPort
+			}
+			break
+		}
+	}
+
+	if ip, ok := o.resolve[net.JoinHostPort(host, port)]; ok {
+		host = ip
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// dialContext wraps dial so that it connects to the overridden addresses.
+func (o *addressOverrides) dialContext(dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	if len(o.connectTo) == 0 && len(o.resolve) == 0 {
+		return dial
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dial(ctx, network, o.address(address))
+	}
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddressOverrides_Address(t *testing.T) {
+	o, err := transportConfig{
+		Resolve: map[string]string{
+			"green.example.com:443": "10.0.0.2",
+			"LB.example.com:8443":   "[2001:db8::1]",
+		},
+		ConnectTo: []string{
+			"api.example.com:443:green.example.com:",
+			"api.example.com::lb.example.com:8443",
+			":80:[2001:db8::2]:8080",
+		},
+	}.addressOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		address  string
+		expected string
+	}{
+		{"green.example.com:443", "10.0.0.2:443"},
+		{"Green.Example.com:443", "10.0.0.2:443"},
+		{"green.example.com:8443", "green.example.com:8443"},
+		{"api.example.com:443", "10.0.0.2:443"},
+		{"api.example.com:9000", "[2001:db8::1]:8443"},
+		{"other.example.com:80", "[2001:db8::2]:8080"},
+		{"other.example.com:443", "other.example.com:443"},
+	}
+
+	for _, tc := range testCases {
+		if got := o.address(tc.address); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.address, tc.expected, got)
+		}
+	}
+}
+
+func TestAddressOverrides_Invalid(t *testing.T) {
+	testCases := []transportConfig{
+		{Resolve: map[string]string{"example.com": "10.0.0.1"}},
+		{Resolve: map[string]string{"example.com:https": "10.0.0.1"}},
+		{Resolve: map[string]string{"example.com:443": "lb.example.com"}},
+		{ConnectTo: []string{"example.com:443:10.0.0.1"}},
+		{ConnectTo: []string{"example.com:443:[2001:db8::1:443"}},
+		{ConnectTo: []string{"example.com:443::99999"}},
+	}
+
+	for _, c := range testCases {
+		if _, err := newHTTPClient(c); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestAddressOverrides_KeepHostAndServerName(t *testing.T) {
+	hosts := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The certificate of httptest servers is valid for example.com, which
+	// only the overrides make reach the server.
+	testCases := []struct {
+		name   string
+		config transportConfig
+		url    string
+		host   string
+	}{
+		{
+			name:   "resolve",
+			config: transportConfig{Resolve: map[string]string{"example.com:" + port: "127.0.0.1"}},
+			url:    "https://example.com:" + port + "/health",
+			host:   "example.com:" + port,
+		},
+		{
+			name:   "connect_to",
+			config: transportConfig{ConnectTo: []string{"example.com:443:127.0.0.1:" + port}},
+			url:    "https://example.com/health",
+			host:   "example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config.CACertPEM = testServerCertPEM(server)
+			if err := testGet(t, tc.config, tc.url); err != nil {
+				t.Fatalf("expected the certificate to be verified for example.com, got %s", err)
+			}
+
+			if host := <-hosts; host != tc.host {
+				t.Errorf("expected the Host header %s, got %s", tc.host, host)
+			}
+		})
+	}
+}
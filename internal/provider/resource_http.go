@@ -3,20 +3,36 @@ package provider
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func resourceUser() *schema.Resource {
 	return &schema.Resource{
-		Create: Create,
-		Update: Update,
-		Read:   ReadUrl,
-		Delete: Delete,
+		Description: `
+		The ` + "`http-wait`" + ` resource waits for the given URL to respond when it is created.
 
-		Schema: map[string]*schema.Schema{
+		It can also manage an object of a REST API through the ` + "`create`" + `, ` + "`read`" + `,
+		` + "`update`" + ` and ` + "`destroy`" + ` blocks, each describing the request made in that phase
+		of the lifecycle. Every request is retried with the exponential backoff settings of the resource.`,
+
+		CreateContext: Create,
+		UpdateContext: Update,
+		ReadContext:   ReadUrl,
+		DeleteContext: Delete,
+
+		CustomizeDiff: customizeDiff,
+
+		Schema: mergeSchemas(map[string]*schema.Schema{
 			"url": {
+				Description: "The URL for the request. When lifecycle blocks are used, this is the base URL their `path` is appended to. " +
+					"Besides `http` and `https` URLs, `unix:///var/run/app.sock:/api` requests `/api` over a Unix domain socket. A relative URL is appended to the `base_url` of the provider.",
 				Type:     schema.TypeString,
 				Required: true,
 			},
@@ -27,74 +43,414 @@ func resourceUser() *schema.Resource {
 				Computed:    true,
 			},
 
-			"initial_interval": {
-				Description: "The initial exponential backoff interval.",
-				Type:        schema.TypeInt,
-				Optional:    true,
+			"id_attribute": {
+				Description: "A JSONPath expression, such as `$.id` or `data.id`, selecting the ID of the object in the " +
+					"body of the create response. When unset, the URL is used as the ID.",
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
 			},
 
-			"max_elapsed_time": {
-				Description: "The maximum time to wait for.",
-				Type:        schema.TypeInt,
-				Optional:    true,
-			},
-			"randomization_factor": {
-				Description: "Randomization factor for exponential backoff.",
-				Type:        schema.TypeString,
-				Optional:    true,
-			},
-			"multiplier": {
-				Description: "Multiplier for exponential backoff.",
+			"auth": authSchema(),
+
+			"oauth2": oauth2Schema("auth"),
+
+			"aws_sigv4": sigv4Schema("auth", "oauth2"),
+
+			"hmac_signature": hmacSchema(),
+
+			"create":  phaseSchema("create", http.MethodPost, false),
+			"read":    phaseSchema("read", http.MethodGet, true),
+			"update":  phaseSchema("update", http.MethodPut, false),
+			"destroy": phaseSchema("destroy", http.MethodDelete, true),
+
+			"response_body": {
+				Description: "The body of the last create, read or update response.",
 				Type:        schema.TypeString,
-				Optional:    true,
+				Computed:    true,
 			},
-			"max_interval": {
-				Description: "Maximum interval factor for exponential backoff.",
+
+			"status_code": {
+				Description: "The status code of the last create, read or update response.",
 				Type:        schema.TypeInt,
-				Optional:    true,
+				Computed:    true,
+			},
+
+			"response_cookies": {
+				Description: "The cookies set by the last create, read or update response, and by the redirects that led to it, by name.",
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Computed:    true,
+				Sensitive:   true,
+			},
+		}, requestSchema(), redirectSchema(), cookiesSchema(), backoffSchema(), transportSchema(), downloadSchema()),
+	}
+}
+
+// phaseSchema returns the block describing the request made in one phase of
+// the resource lifecycle.
+func phaseSchema(phase, defaultMethod string, notFoundExpected bool) *schema.Schema {
+	expected := "`2xx`"
+	if notFoundExpected {
+		expected = "`2xx` and `404`"
+	}
+
+	return &schema.Schema{
+		Description: fmt.Sprintf("The request made to %s the object.", phase),
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"method": {
+					Description:  fmt.Sprintf("The HTTP method of the request. Defaults to `%s`.", defaultMethod),
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      defaultMethod,
+					ValidateFunc: validation.StringInSlice(supportedMethods, false),
+				},
+
+				"path": {
+					Description: "The path appended to `url`. Any `{id}` placeholder is replaced with the ID of the object, which requires `id_attribute`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"request_headers": {
+					Description: "A map of request header field names and values.",
+					Type:        schema.TypeMap,
+					Elem:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"request_body": {
+					Description: "The request body as a string.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"expected_status": {
+					Description: fmt.Sprintf("A set of response status codes that define a successful request, using the same "+
+						"syntax as `retry_on_status`. Defaults to the top-level `expected_status`, or to %s.", expected),
+					Type: schema.TypeSet,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validateStatusPattern,
+					},
+					Optional: true,
+				},
 			},
 		},
 	}
 }
 
-func Create(d *schema.ResourceData, meta interface{}) error {
-	url := d.Get("url").(string)
+// phaseRequest is the expanded form of a lifecycle block.
+type phaseRequest struct {
+	Method         string
+	Path           string
+	Headers        map[string]interface{}
+	Body           string
+	ExpectedStatus []string
+}
+
+// expandPhase returns the request configured for the given phase, or nil
+// when the block is not set.
+func expandPhase(d *schema.ResourceData, phase string) *phaseRequest {
+	blocks := d.Get(phase).([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return nil
+	}
+
+	block := blocks[0].(map[string]interface{})
+	return &phaseRequest{
+		Method:         block["method"].(string),
+		Path:           block["path"].(string),
+		Headers:        block["request_headers"].(map[string]interface{}),
+		Body:           block["request_body"].(string),
+		ExpectedStatus: expandStringList(block["expected_status"].(*schema.Set).List()),
+	}
+}
+
+func (p *phaseRequest) newRequest(ctx context.Context, baseURL, id string) (*http.Request, error) {
+	target := baseURL + strings.ReplaceAll(p.Path, "{id}", url.PathEscape(id))
+
+	var request *http.Request
+	var err error
+	if p.Body != "" {
+		request, err = http.NewRequestWithContext(ctx, p.Method, target, strings.NewReader(p.Body))
+	} else {
+		request, err = http.NewRequestWithContext(ctx, p.Method, target, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range p.Headers {
+		request.Header.Set(name, value.(string))
+	}
+
+	return request, nil
+}
+
+// doPhase makes the request of a lifecycle block with the backoff settings of
+// the resource, and returns the response along with its body.
+func doPhase(ctx context.Context, d *schema.ResourceData, meta interface{}, phase string, p *phaseRequest) (*http.Response, []byte, diag.Diagnostics) {
+	c := meta.(*apiClient)
+
+	request, err := p.newRequest(ctx, c.resolveURL(d.Get("url").(string)), d.Id())
+	if err != nil {
+		return nil, nil, diag.Diagnostics{{
+			Summary: fmt.Sprintf("Error creating %s request", phase),
+			Detail:  fmt.Sprintf("Error creating %s request: %s", phase, err),
+		}}
+	}
+
+	opts := c.backoffOptions(d)
+	if phase != "create" {
+		// Success conditions describe the object becoming ready, and the
+		// output file holds the object created, which only makes sense for
+		// the response to its creation.
+		opts.SuccessConditions = nil
+		opts.OutputFile = ""
+		opts.ExpectedSHA256 = ""
+	}
+
+	if len(p.ExpectedStatus) > 0 {
+		opts.ExpectedStatus = p.ExpectedStatus
+	} else if len(opts.ExpectedStatus) == 0 {
+		opts.ExpectedStatus = []string{"2xx"}
+		if phase == "read" || phase == "destroy" {
+			opts.ExpectedStatus = append(opts.ExpectedStatus, "404")
+		}
+	}
+
+	return doResourceRequest(ctx, d, c, request, opts)
+}
+
+func doResourceRequest(ctx context.Context, d *schema.ResourceData, c *apiClient, request *http.Request, opts backoffOptions) (*http.Response, []byte, diag.Diagnostics) {
+	c.setDefaultHeaders(request)
+	addCookies(d, request)
 
-	request, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	client, err := c.httpClient(d)
 	if err != nil {
-		return err
+		return nil, nil, diag.Diagnostics{{Summary: "Error configuring the HTTP client", Detail: err.Error()}}
 	}
 
-	_, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(),
-		request,
-		int64(d.Get("initial_interval").(int)),
-		int64(d.Get("max_elapsed_time").(int)),
-		int64(d.Get("max_interval").(int)),
-		d.Get("randomization_factor").(string),
-		d.Get("multiplier").(string),
-	)
+	client, errSummary, errDesc := c.sessionClient(ctx, d, client, opts)
+	if len(errSummary) > 0 {
+		return nil, nil, diag.Diagnostics{{Summary: errSummary, Detail: errDesc}}
+	}
 
+	response, errSummary, errDesc := makeExponentialBackoffRequest(ctx, client, request, opts)
 	if len(errSummary) > 0 {
-		return fmt.Errorf("%s : %s", errSummary, errDesc)
+		return nil, nil, diag.Diagnostics{{Summary: errSummary, Detail: errDesc}}
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, diag.Diagnostics{{
+			Summary: "Error reading response body",
+			Detail:  fmt.Sprintf("Error reading response body: %s", err),
+		}}
+	}
+
+	return response, body, nil
+}
+
+func setResponse(d *schema.ResourceData, response *http.Response, body []byte) diag.Diagnostics {
+	if err := d.Set("response_body", string(body)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("status_code", response.StatusCode); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("response_cookies", responseCookies(response)); err != nil {
+		return diag.FromErr(err)
 	}
 
-	d.SetId(url)
 	return nil
 }
 
-func Update(d *schema.ResourceData, meta interface{}) error {
+func Create(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	c := meta.(*apiClient)
+	url := c.resolveURL(d.Get("url").(string))
+
+	var response *http.Response
+	var body []byte
+	var diags diag.Diagnostics
+
+	if p := expandPhase(d, "create"); p != nil {
+		response, body, diags = doPhase(ctx, d, meta, "create", p)
+	} else {
+		request, err := newRequestFromResourceData(ctx, d, url)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		response, body, diags = doResourceRequest(ctx, d, c, request, c.backoffOptions(d))
+	}
+	if diags.HasError() {
+		return diags
+	}
+
+	id := url
+	if path, ok := d.GetOk("id_attribute"); ok {
+		var err error
+		id, err = extractID(body, path.(string))
+		if err != nil {
+			return diag.Diagnostics{{
+				Summary: "Error extracting the ID from the create response",
+				Detail:  err.Error(),
+			}}
+		}
+	}
+
+	d.SetId(id)
+
+	if diags := setResponse(d, response, body); diags.HasError() {
+		return diags
+	}
+
+	return readApplied(ctx, d, meta, "created")
+}
+
+func extractID(body []byte, path string) (string, error) {
+	document, err := decodeJSON(body)
+	if err != nil {
+		return "", fmt.Errorf("create response is not valid JSON: %w", err)
+	}
+
+	value, err := jsonPathLookup(document, path)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := jsonValueString(value)
+	if err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("%s selects an empty value", path)
+	}
 
-	return ReadUrl(d, meta)
+	return id, nil
 }
 
-func ReadUrl(d *schema.ResourceData, meta interface{}) error {
+func Update(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if p := expandPhase(d, "update"); p != nil {
+		response, body, diags := doPhase(ctx, d, meta, "update", p)
+		if diags.HasError() {
+			return diags
+		}
 
-	return nil
+		if diags := setResponse(d, response, body); diags.HasError() {
+			return diags
+		}
+	}
+
+	return readApplied(ctx, d, meta, "updated")
+}
+
+func ReadUrl(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	found, diags := readObject(ctx, d, meta)
+	if !diags.HasError() && !found {
+		// The object is gone, let Terraform plan to create it again.
+		d.SetId("")
+	}
+
+	return diags
+}
+
+// readApplied reads the object once it was created or updated. Unlike a
+// refresh, a missing object is an error, as removing it from the state during
+// apply makes the result of the apply inconsistent.
+func readApplied(ctx context.Context, d *schema.ResourceData, meta interface{}, action string) diag.Diagnostics {
+	found, diags := readObject(ctx, d, meta)
+	if diags.HasError() || found {
+		return diags
+	}
+
+	return diag.Diagnostics{{
+		Summary: "Object not found after apply",
+		Detail:  fmt.Sprintf("The read request got a 404 response for the object %s just %s.", d.Id(), action),
+	}}
+}
+
+// readObject makes the read request of the resource, if any, and reports
+// whether the object was found.
+func readObject(ctx context.Context, d *schema.ResourceData, meta interface{}) (bool, diag.Diagnostics) {
+	p := expandPhase(d, "read")
+	if p == nil {
+		return true, nil
+	}
+
+	response, body, diags := doPhase(ctx, d, meta, "read", p)
+	if diags.HasError() {
+		return false, diags
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	return true, setResponse(d, response, body)
 }
 
-func Delete(d *schema.ResourceData, meta interface{}) error {
+func Delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if p := expandPhase(d, "destroy"); p != nil {
+		if _, _, diags := doPhase(ctx, d, meta, "destroy", p); diags.HasError() {
+			return diags
+		}
+	}
 
 	d.SetId("")
 
 	return nil
 }
+
+// customizeDiff checks the {id} placeholders of the lifecycle paths, and
+// forces a new object when the request that created it changes and there is
+// no update block to apply the change in place.
+func customizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.NewValueKnown("id_attribute") {
+		if err := checkIDPlaceholders(d); err != nil {
+			return err
+		}
+	}
+
+	if d.Id() == "" || len(d.Get("update").([]interface{})) > 0 {
+		return nil
+	}
+
+	for _, key := range []string{"url", "create", "method", "request_body", "request_body_base64", "output_file", "expected_sha256"} {
+		if d.HasChange(key) {
+			if err := d.ForceNew(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkIDPlaceholders returns an error when the path of a lifecycle block
+// uses the {id} placeholder without id_attribute, as the ID is then the URL
+// itself.
+func checkIDPlaceholders(d interface{ Get(string) interface{} }) error {
+	if d.Get("id_attribute").(string) != "" {
+		return nil
+	}
+
+	for _, phase := range []string{"read", "update", "destroy"} {
+		block := firstBlock(d.Get(phase))
+		if block != nil && strings.Contains(block["path"].(string), "{id}") {
+			return fmt.Errorf("%s.path: the {id} placeholder requires id_attribute, without which the ID is the URL", phase)
+		}
+	}
+
+	return nil
+}
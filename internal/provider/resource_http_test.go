@@ -1,11 +1,19 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestResourceSetsUrlInState(t *testing.T) {
@@ -88,3 +96,187 @@ func TestResourceSNonExistingURL(t *testing.T) {
 		},
 	})
 }
+
+func TestResourceLifecycle(t *testing.T) {
+	var items sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/items":
+			body, _ := io.ReadAll(r.Body)
+			items.Store("42", string(body))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"data": {"id": 42}}`))
+		case strings.HasPrefix(r.URL.Path, "/items/"):
+			id := strings.TrimPrefix(r.URL.Path, "/items/")
+			item, ok := items.Load(id)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			switch r.Method {
+			case http.MethodGet:
+				_, _ = w.Write([]byte(item.(string)))
+			case http.MethodPut:
+				body, _ := io.ReadAll(r.Body)
+				items.Store(id, string(body))
+				w.WriteHeader(http.StatusNoContent)
+			case http.MethodDelete:
+				items.Delete(id)
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	config := func(name string) string {
+		return fmt.Sprintf(`
+				resource "http-wait" "example" {
+					url          = "%s"
+					id_attribute = "$.data.id"
+
+					max_elapsed_time = 5
+					initial_interval = 100
+
+					create {
+						path         = "/items"
+						request_body = jsonencode({ name = "%[2]s" })
+					}
+
+					read {
+						path = "/items/{id}"
+					}
+
+					update {
+						path         = "/items/{id}"
+						request_body = jsonencode({ name = "%[2]s" })
+					}
+
+					destroy {
+						path = "/items/{id}"
+					}
+				}`, server.URL, name)
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: providerFactories,
+		CheckDestroy: func(*terraform.State) error {
+			if _, ok := items.Load("42"); ok {
+				return fmt.Errorf("item 42 still exists")
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: config("first"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("http-wait.example", "id", "42"),
+					resource.TestCheckResourceAttr("http-wait.example", "status_code", "200"),
+					resource.TestCheckResourceAttr("http-wait.example", "response_body", `{"name":"first"}`),
+				),
+			},
+			{
+				Config: config("second"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("http-wait.example", "id", "42"),
+					resource.TestCheckResourceAttr("http-wait.example", "response_body", `{"name":"second"}`),
+				),
+			},
+		},
+	})
+}
+
+func TestCheckIDPlaceholders(t *testing.T) {
+	testCases := []struct {
+		name  string
+		raw   map[string]interface{}
+		valid bool
+	}{
+		{
+			name: "id_attribute",
+			raw: map[string]interface{}{
+				"id_attribute": "$.id",
+				"read":         []interface{}{map[string]interface{}{"path": "/items/{id}"}},
+			},
+			valid: true,
+		},
+		{
+			name: "no placeholder",
+			raw: map[string]interface{}{
+				"read": []interface{}{map[string]interface{}{"path": "/health"}},
+			},
+			valid: true,
+		},
+		{
+			name: "placeholder without id_attribute",
+			raw: map[string]interface{}{
+				"destroy": []interface{}{map[string]interface{}{"path": "/items/{id}"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["url"] = "https://example.com"
+			d := schema.TestResourceDataRaw(t, resourceUser().Schema, tc.raw)
+
+			if err := checkIDPlaceholders(d); (err == nil) != tc.valid {
+				t.Errorf("expected valid: %t, got %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func TestLifecycle_NotFoundAfterCreate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			_, _ = w.Write([]byte(`{"id": 42}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, resourceUser().Schema, map[string]interface{}{
+		"url":              server.URL,
+		"id_attribute":     "$.id",
+		"max_elapsed_time": 1,
+		"create":           []interface{}{map[string]interface{}{"path": "/items"}},
+		"read":             []interface{}{map[string]interface{}{"path": "/items/{id}"}},
+	})
+
+	diags := Create(context.Background(), d, testApiClient(t, nil))
+	if !diags.HasError() || !strings.Contains(diags[0].Detail, "404") {
+		t.Fatalf("expected a 404 after create to fail the apply, got %v", diags)
+	}
+
+	if d.Id() != "42" {
+		t.Errorf("expected the created object to be kept in the state, got the ID %q", d.Id())
+	}
+}
+
+func TestLifecycle_ResponseCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.Method})
+		_, _ = w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, resourceUser().Schema, map[string]interface{}{
+		"url":              server.URL,
+		"id_attribute":     "$.id",
+		"max_elapsed_time": 1,
+		"create":           []interface{}{map[string]interface{}{"path": "/items"}},
+		"read":             []interface{}{map[string]interface{}{"path": "/items/{id}"}},
+	})
+
+	if diags := Create(context.Background(), d, testApiClient(t, nil)); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	if cookies := d.Get("response_cookies").(map[string]interface{}); cookies["session"] != http.MethodGet {
+		t.Errorf("expected the cookies of the last response, got %v", cookies)
+	}
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// responseBodySchema returns the data source attributes that expose the raw
+// response body, which work for binary content types too.
+func responseBodySchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"allowed_content_types": {
+			Description: "Media types, such as `application/octet-stream` or `application/*`, accepted in addition to text types. " +
+				"`*/*` accepts any response. The body of such a response is only exported in `response_body_base64` and the checksum attributes.",
+			Type:     schema.TypeList,
+			Elem:     &schema.Schema{Type: schema.TypeString, ValidateFunc: validateMediaTypePattern},
+			Optional: true,
+		},
+
+		"response_body_base64": {
+			Description: "The response body encoded as base64, which preserves binary content. Empty when the body is exported as text in `response_body`.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"response_body_sha256": {
+			Description: "The hex encoded SHA-256 checksum of the response body.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"response_body_sha512": {
+			Description: "The hex encoded SHA-512 checksum of the response body.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"response_body_md5": {
+			Description: "The hex encoded MD5 checksum of the response body, for services that publish nothing stronger.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+	}
+}
+
+// isContentTypeAllowed reports whether contentType matches one of patterns,
+// which are media types where the type or subtype may be a wildcard.
+func isContentTypeAllowed(contentType string, patterns []string) bool {
+	parsedType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		parsedType = ""
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*/*" {
+			return true
+		}
+
+		if matched, _ := path.Match(pattern, parsedType); matched && parsedType != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateMediaTypePattern(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	parts := strings.Split(v, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, []error{fmt.Errorf("%s: %q is not a media type such as application/octet-stream or application/*", k, v)}
+	}
+
+	if _, err := path.Match(v, ""); err != nil {
+		return nil, []error{fmt.Errorf("%s: %q is not a valid pattern: %w", k, v, err)}
+	}
+
+	return nil, nil
+}
+
+// setResponseBodyEncodings sets the checksums of body, and its base64
+// encoding unless it is exported as text, so that the state does not hold
+// the body twice.
+func setResponseBodyEncodings(d *schema.ResourceData, body []byte, isText bool) error {
+	values, err := bodyChecksums(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	values["response_body_base64"] = ""
+	if !isText {
+		values["response_body_base64"] = base64.StdEncoding.EncodeToString(body)
+	}
+
+	return setStrings(d, values)
+}
+
+// setResponseFileChecksums sets the checksums of a response body that was
+// saved to path. The body is not encoded, as it may be too large for the state.
+func setResponseFileChecksums(d *schema.ResourceData, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	values, err := bodyChecksums(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	values["response_body_base64"] = ""
+
+	return setStrings(d, values)
+}
+
+// bodyChecksums returns the checksum attributes of the body read from r.
+func bodyChecksums(r io.Reader) (map[string]string, error) {
+	sha256Hash := sha256.New()
+	sha512Hash := sha512.New()
+	md5Hash := md5.New() //nolint:gosec
+
+	if _, err := io.Copy(io.MultiWriter(sha256Hash, sha512Hash, md5Hash), r); err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"response_body_sha256": hex.EncodeToString(sha256Hash.Sum(nil)),
+		"response_body_sha512": hex.EncodeToString(sha512Hash.Sum(nil)),
+		"response_body_md5":    hex.EncodeToString(md5Hash.Sum(nil)),
+	}, nil
+}
+
+func setStrings(d *schema.ResourceData, values map[string]string) error {
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestIsContentTypeAllowed(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		patterns    []string
+		expected    bool
+	}{
+		{contentType: "application/zip", patterns: nil, expected: false},
+		{contentType: "application/zip", patterns: []string{"application/zip"}, expected: true},
+		{contentType: "Application/ZIP", patterns: []string{"application/zip"}, expected: true},
+		{contentType: "application/gzip", patterns: []string{"application/zip"}, expected: false},
+		{contentType: "application/octet-stream", patterns: []string{"application/*"}, expected: true},
+		{contentType: "image/png", patterns: []string{"application/*"}, expected: false},
+		{contentType: "image/png", patterns: []string{"*/*"}, expected: true},
+		{contentType: "", patterns: []string{"application/*"}, expected: false},
+		{contentType: "", patterns: []string{"*/*"}, expected: true},
+	}
+
+	for _, tc := range testCases {
+		if got := isContentTypeAllowed(tc.contentType, tc.patterns); got != tc.expected {
+			t.Errorf("isContentTypeAllowed(%q, %q) = %t, expected %t", tc.contentType, tc.patterns, got, tc.expected)
+		}
+	}
+}
+
+func TestValidateMediaTypePattern(t *testing.T) {
+	for _, v := range []string{"application/zip", "application/*", "*/*", "application/vnd.*+zip"} {
+		if _, errs := validateMediaTypePattern(v, "allowed_content_types"); len(errs) > 0 {
+			t.Errorf("expected %q to be valid, got %v", v, errs)
+		}
+	}
+
+	for _, v := range []string{"zip", "application/", "/zip", "application/zip/x", "application/[a"} {
+		if _, errs := validateMediaTypePattern(v, "allowed_content_types"); len(errs) == 0 {
+			t.Errorf("expected %q to be invalid", v)
+		}
+	}
+}
+
+func TestSetResponseBodyEncodings(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com"})
+	if err := setResponseBodyEncodings(d, []byte("abc"), false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"response_body_base64": "YWJj",
+		"response_body_sha256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"response_body_sha512": "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f",
+		"response_body_md5":    "900150983cd24fb0d6963f7d28e17f72",
+	}
+	for k, v := range expected {
+		if got := d.Get(k).(string); got != v {
+			t.Errorf("expected %s to be %s, got %s", k, v, got)
+		}
+	}
+
+	if err := setResponseBodyEncodings(d, []byte("abc"), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Get("response_body_base64").(string); got != "" {
+		t.Errorf("expected a text body not to be encoded, got %s", got)
+	}
+	if got := d.Get("response_body_sha256").(string); got != expected["response_body_sha256"] {
+		t.Errorf("expected the checksums of a text body, got %s", got)
+	}
+}
@@ -0,0 +1,360 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// responseFormatsSchema returns the data source attributes that expose the
+// response body decoded according to its content type.
+func responseFormatsSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"json_fields": {
+			Description: "A map of names to JSONPath expressions, such as `$.version`, evaluated against a JSON response body. " +
+				"The selected values are exported in `response_json_fields`.",
+			Type:     schema.TypeMap,
+			Elem:     schema.TypeString,
+			Optional: true,
+		},
+
+		"response_json": {
+			Description: "The JSON response body flattened into a map, keyed by the dot separated path of every leaf value, " +
+				"such as `versions.0.name`. Dots and backslashes in object keys are escaped with a backslash. Non-empty objects " +
+				"and arrays are left out: decode `response_body` with `jsondecode` to get them. Empty unless the response content type is JSON.",
+			Type:     schema.TypeMap,
+			Elem:     schema.TypeString,
+			Computed: true,
+		},
+
+		"response_json_fields": {
+			Description: "The values selected by `json_fields`, keyed by the same names. Objects and arrays are rendered as JSON.",
+			Type:        schema.TypeMap,
+			Elem:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"response_yaml": {
+			Description: "The YAML response body flattened into a map in the same way as `response_json`. " +
+				"Empty unless the response content type is YAML.",
+			Type:     schema.TypeMap,
+			Elem:     schema.TypeString,
+			Computed: true,
+		},
+
+		"xml_fields": {
+			Description: "A map of names to XPath expressions, such as `//release/@version` or `count(//node)`, evaluated against an " +
+				"XML response body. The selected values are exported in `response_xml_fields`.",
+			Type:     schema.TypeMap,
+			Elem:     schema.TypeString,
+			Optional: true,
+		},
+
+		"response_xml_fields": {
+			Description: "The values selected by `xml_fields`, keyed by the same names. A node set is rendered as the text of its first node.",
+			Type:        schema.TypeMap,
+			Elem:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"response_csv": {
+			Description: "The rows of a CSV response body, each a map keyed by the column names of the header row. " +
+				"Empty unless the response content type is `text/csv`.",
+			Type:     schema.TypeList,
+			Elem:     &schema.Schema{Type: schema.TypeMap, Elem: &schema.Schema{Type: schema.TypeString}},
+			Computed: true,
+		},
+	}
+}
+
+// setResponseFormats sets the attributes that expose the response body
+// decoded according to its content type.
+func setResponseFormats(d *schema.ResourceData, contentType string, body []byte) error {
+	if err := setResponseJSON(d, contentType, body); err != nil {
+		return err
+	}
+
+	if err := setResponseYAML(d, contentType, body); err != nil {
+		return err
+	}
+
+	if err := setResponseXML(d, contentType, body); err != nil {
+		return err
+	}
+
+	return setResponseCSV(d, contentType, body)
+}
+
+// isContentTypeJSON reports whether contentType is application/json or a
+// structured syntax type based on it, such as application/problem+json.
+func isContentTypeJSON(contentType string) bool {
+	parsedType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return parsedType == "application/json" || strings.HasSuffix(parsedType, "+json")
+}
+
+// isContentTypeYAML reports whether contentType is one of the media types in
+// use for YAML, registered or not.
+func isContentTypeYAML(contentType string) bool {
+	parsedType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch parsedType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+
+	return strings.HasSuffix(parsedType, "+yaml")
+}
+
+// isContentTypeXML reports whether contentType is application/xml, text/xml
+// or a structured syntax type based on them, such as application/atom+xml.
+func isContentTypeXML(contentType string) bool {
+	parsedType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return parsedType == "application/xml" || parsedType == "text/xml" || strings.HasSuffix(parsedType, "+xml")
+}
+
+func isContentTypeCSV(contentType string) bool {
+	parsedType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return parsedType == "text/csv"
+}
+
+// setResponseJSON decodes a JSON response body into response_json and
+// evaluates json_fields against it.
+func setResponseJSON(d *schema.ResourceData, contentType string, body []byte) error {
+	flattened := map[string]string{}
+	fields := map[string]string{}
+	jsonFields := d.Get("json_fields").(map[string]interface{})
+
+	if isContentTypeJSON(contentType) || len(jsonFields) > 0 {
+		document, err := decodeJSON(body)
+		if err != nil {
+			if len(jsonFields) > 0 {
+				return fmt.Errorf("json_fields is set but the response body is not valid JSON: %w", err)
+			}
+			// A server may label any body as JSON. This only leaves
+			// response_json empty, as it did before it was introduced.
+			document = nil
+		}
+
+		if err := flattenDocument("", document, flattened); err != nil {
+			return err
+		}
+
+		for name, path := range jsonFields {
+			value, err := jsonPathLookup(document, path.(string))
+			if err != nil {
+				return fmt.Errorf("json_fields.%s: %w", name, err)
+			}
+
+			fields[name], err = jsonValueString(value)
+			if err != nil {
+				return fmt.Errorf("json_fields.%s: %w", name, err)
+			}
+		}
+	}
+
+	if err := d.Set("response_json", flattened); err != nil {
+		return err
+	}
+
+	return d.Set("response_json_fields", fields)
+}
+
+// flattenDocument adds the leaf values of a decoded document to flattened,
+// keyed by their dot separated path below prefix. Terraform Plugin SDK v2 has
+// no dynamic attribute type, so this is how the structure is made navigable.
+// Objects and arrays are only added when they are empty: the others remain
+// reachable by decoding the whole body, and adding them would repeat every
+// value once per level of nesting.
+func flattenDocument(prefix string, value interface{}, flattened map[string]string) error {
+	var children map[string]interface{}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		children = v
+	case []interface{}:
+		children = make(map[string]interface{}, len(v))
+		for i, child := range v {
+			children[strconv.Itoa(i)] = child
+		}
+	default:
+		return nil
+	}
+
+	for key, child := range children {
+		path := escapePathSegment(key)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		nested := false
+		switch c := child.(type) {
+		case map[string]interface{}:
+			nested = len(c) > 0
+		case []interface{}:
+			nested = len(c) > 0
+		}
+
+		if nested {
+			if err := flattenDocument(path, child, flattened); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rendered, err := jsonValueString(child)
+		if err != nil {
+			return err
+		}
+		flattened[path] = rendered
+	}
+
+	return nil
+}
+
+// pathSegmentEscaper escapes the dots of object keys, and the backslashes
+// that escape them, so that the key a.b and the path a.b do not collide.
+var pathSegmentEscaper = strings.NewReplacer(`\`, `\\`, `.`, `\.`)
+
+func escapePathSegment(key string) string {
+	return pathSegmentEscaper.Replace(key)
+}
+
+// setResponseYAML decodes a YAML response body into response_yaml.
+func setResponseYAML(d *schema.ResourceData, contentType string, body []byte) error {
+	flattened := map[string]string{}
+
+	if isContentTypeYAML(contentType) {
+		var document interface{}
+		// As with JSON, a body that does not decode leaves response_yaml empty.
+		if err := yaml.Unmarshal(body, &document); err == nil {
+			if err := flattenDocument("", normalizeYAML(document), flattened); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.Set("response_yaml", flattened)
+}
+
+// normalizeYAML converts a decoded YAML document into the types of a decoded
+// JSON document, so that it can be flattened and rendered the same way.
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = normalizeYAML(child)
+		}
+		return v
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			normalized[fmt.Sprint(key)] = normalizeYAML(child)
+		}
+		return normalized
+	case []interface{}:
+		for i, child := range v {
+			v[i] = normalizeYAML(child)
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// setResponseXML evaluates xml_fields against an XML response body.
+func setResponseXML(d *schema.ResourceData, contentType string, body []byte) error {
+	fields := map[string]string{}
+	xmlFields := d.Get("xml_fields").(map[string]interface{})
+
+	if len(xmlFields) > 0 {
+		document, err := xmlquery.Parse(bytes.NewReader(body))
+		if err != nil {
+			if !isContentTypeXML(contentType) {
+				return fmt.Errorf("xml_fields is set but the response content type is %q and the body is not valid XML: %w", contentType, err)
+			}
+			return fmt.Errorf("xml_fields is set but the response body is not valid XML: %w", err)
+		}
+
+		for name, expr := range xmlFields {
+			fields[name], err = xpathValueString(document, expr.(string))
+			if err != nil {
+				return fmt.Errorf("xml_fields.%s: %w", name, err)
+			}
+		}
+	}
+
+	return d.Set("response_xml_fields", fields)
+}
+
+// xpathValueString evaluates the XPath expression expr against document and
+// renders the result as a string.
+func xpathValueString(document *xmlquery.Node, expr string) (string, error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid XPath expression %q: %w", expr, err)
+	}
+
+	switch v := compiled.Evaluate(xmlquery.CreateXPathNavigator(document)).(type) {
+	case *xpath.NodeIterator:
+		if !v.MoveNext() {
+			return "", fmt.Errorf("%s selected no node", expr)
+		}
+		return v.Current().Value(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		return v, nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// setResponseCSV decodes a CSV response body into response_csv, using the
+// first record as the column names.
+func setResponseCSV(d *schema.ResourceData, contentType string, body []byte) error {
+	rows := []interface{}{}
+
+	if isContentTypeCSV(contentType) {
+		// As with JSON, a body that does not decode leaves response_csv empty.
+		if records, err := csv.NewReader(bytes.NewReader(body)).ReadAll(); err == nil && len(records) > 0 {
+			header := records[0]
+			for _, record := range records[1:] {
+				row := make(map[string]interface{}, len(header))
+				for i, column := range header {
+					row[column] = record[i]
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	return d.Set("response_csv", rows)
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestIsContentTypeJSON(t *testing.T) {
+	testCases := map[string]bool{
+		"application/json":                 true,
+		"application/json; charset=utf-8":  true,
+		"application/problem+json":         true,
+		"application/vnd.api+json":         true,
+		"text/plain":                       false,
+		"application/jsonp":                false,
+		"not a media type; charset=utf-8;": false,
+	}
+
+	for contentType, expected := range testCases {
+		if got := isContentTypeJSON(contentType); got != expected {
+			t.Errorf("isContentTypeJSON(%q) = %t, expected %t", contentType, got, expected)
+		}
+	}
+}
+
+func TestSetResponseJSON(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url": "https://example.com",
+		"json_fields": map[string]interface{}{
+			"version":  "$.version",
+			"endpoint": "$.endpoints[1].url",
+			"meta":     "meta",
+		},
+	})
+
+	body := []byte(`{"version": "1.2.3", "stable": true, "endpoints": [{"url": "a"}, {"url": "b"}], "meta": {"count": 2}, ` +
+		`"labels": {"app.kubernetes.io/name": "web", "app": {"kubernetes": {"io/name": "other"}}, "a\\b": "c"}, "tags": [], "extra": {}}`)
+	if err := setResponseJSON(d, "application/json", body); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedJSON := map[string]interface{}{
+		"version":                         "1.2.3",
+		"stable":                          "true",
+		"endpoints.0.url":                 "a",
+		"endpoints.1.url":                 "b",
+		"meta.count":                      "2",
+		`labels.app\.kubernetes\.io/name`: "web",
+		"labels.app.kubernetes.io/name":   "other",
+		`labels.a\\b`:                     "c",
+		"tags":                            "[]",
+		"extra":                           "{}",
+	}
+	if got := d.Get("response_json").(map[string]interface{}); !reflect.DeepEqual(got, expectedJSON) {
+		t.Errorf("unexpected response_json:\n got: %v\nwant: %v", got, expectedJSON)
+	}
+
+	expectedFields := map[string]interface{}{
+		"version":  "1.2.3",
+		"endpoint": "b",
+		"meta":     `{"count":2}`,
+	}
+	if got := d.Get("response_json_fields").(map[string]interface{}); !reflect.DeepEqual(got, expectedFields) {
+		t.Errorf("unexpected response_json_fields:\n got: %v\nwant: %v", got, expectedFields)
+	}
+}
+
+func TestSetResponseJSON_NotJSON(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com"})
+	if err := setResponseJSON(d, "text/plain", []byte("1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := d.Get("response_json").(map[string]interface{}); len(got) != 0 {
+		t.Errorf("expected an empty response_json for a text body, got %v", got)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":         "https://example.com",
+		"json_fields": map[string]interface{}{"version": "$.version"},
+	})
+	if err := setResponseJSON(d, "text/plain", []byte("1.0.0 is not JSON")); err == nil {
+		t.Error("expected an error when json_fields is set and the body is not JSON")
+	}
+}
+
+func TestSetResponseYAML(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com"})
+
+	body := []byte(`
+flags:
+  new-checkout: true
+  rollout: 25
+owners: [payments, web]
+updated: 2022-06-01T10:00:00Z
+1: numeric key
+`)
+	if err := setResponseYAML(d, "application/yaml", body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"flags.new-checkout": "true",
+		"flags.rollout":      "25",
+		"owners.0":           "payments",
+		"owners.1":           "web",
+		"updated":            "2022-06-01T10:00:00Z",
+		"1":                  "numeric key",
+	}
+	if got := d.Get("response_yaml").(map[string]interface{}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected response_yaml:\n got: %v\nwant: %v", got, expected)
+	}
+}
+
+func TestSetResponseXML(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url": "https://example.com",
+		"xml_fields": map[string]interface{}{
+			"status":  "/service/status",
+			"version": "//release/@version",
+			"nodes":   "count(//node)",
+			"healthy": "/service/status = 'UP'",
+		},
+	})
+
+	body := []byte(`<?xml version="1.0"?>
+<service>
+  <status>UP</status>
+  <release version="4.2"/>
+  <node>a</node>
+  <node>b</node>
+</service>`)
+	if err := setResponseXML(d, "application/xml", body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"status":  "UP",
+		"version": "4.2",
+		"nodes":   "2",
+		"healthy": "true",
+	}
+	if got := d.Get("response_xml_fields").(map[string]interface{}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected response_xml_fields:\n got: %v\nwant: %v", got, expected)
+	}
+
+	d = schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url":        "https://example.com",
+		"xml_fields": map[string]interface{}{"missing": "/service/missing"},
+	})
+	if err := setResponseXML(d, "application/xml", body); err == nil {
+		t.Error("expected an error for an XPath expression that selects no node")
+	}
+}
+
+func TestSetResponseCSV(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{"url": "https://example.com"})
+
+	body := []byte("region,status\neu-west-1,UP\n\"us-east-1\",\"DOWN, draining\"\n")
+	if err := setResponseCSV(d, "text/csv; charset=utf-8", body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{
+		map[string]interface{}{"region": "eu-west-1", "status": "UP"},
+		map[string]interface{}{"region": "us-east-1", "status": "DOWN, draining"},
+	}
+	if got := d.Get("response_csv").([]interface{}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected response_csv:\n got: %v\nwant: %v", got, expected)
+	}
+}
@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// sigv4Schema returns the block that signs requests with AWS Signature
+// Version 4.
+func sigv4Schema(conflictsWith ...string) *schema.Schema {
+	return &schema.Schema{
+		Description: "Signs every attempt with AWS Signature Version 4, for endpoints that use IAM authentication such as " +
+			"API Gateway or OpenSearch. Without `access_key`, credentials are found the way the AWS CLI finds them: from the " +
+			"environment, the shared credentials and config files, including `credential_process`, SSO and assumed role " +
+			"profiles, then from the container or instance role.",
+		Type:          schema.TypeList,
+		Optional:      true,
+		MaxItems:      1,
+		ConflictsWith: conflictsWith,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"region": {
+					Description: "The AWS region of the endpoint, such as `eu-west-1`.",
+					Type:        schema.TypeString,
+					Required:    true,
+				},
+
+				"service": {
+					Description: "The signing name of the service, such as `execute-api` or `es`.",
+					Type:        schema.TypeString,
+					Required:    true,
+				},
+
+				"access_key": {
+					Description:  "The access key ID.",
+					Type:         schema.TypeString,
+					Optional:     true,
+					RequiredWith: []string{"aws_sigv4.0.secret_key"},
+				},
+
+				"secret_key": {
+					Description:  "The secret access key.",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Sensitive:    true,
+					RequiredWith: []string{"aws_sigv4.0.access_key"},
+				},
+
+				"session_token": {
+					Description:  "The session token of temporary credentials.",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Sensitive:    true,
+					RequiredWith: []string{"aws_sigv4.0.access_key"},
+				},
+
+				"profile": {
+					Description:   "The profile of the shared credentials and config files to get credentials from. Defaults to `AWS_PROFILE`, or `default`.",
+					Type:          schema.TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"aws_sigv4.0.access_key"},
+				},
+			},
+		},
+	}
+}
+
+// credentialsError is returned when no credentials can be found, which
+// retrying would not fix.
+type credentialsError struct {
+	err error
+}
+
+func (e *credentialsError) Error() string {
+	return fmt.Sprintf("error loading AWS credentials: %s", e.err)
+}
+
+func (e *credentialsError) Unwrap() error {
+	return e.err
+}
+
+// sigv4Signer signs requests with AWS Signature Version 4.
+type sigv4Signer struct {
+	region  string
+	service string
+	now     func() time.Time
+
+	// credentials are resolved on the first attempt, and renewed by the
+	// credentials provider when they expire.
+	credentials aws.CredentialsProvider
+}
+
+func expandSigV4(d *schema.ResourceData) *sigv4Signer {
+	block := firstBlock(d.Get("aws_sigv4"))
+	if block == nil {
+		return nil
+	}
+
+	region := block["region"].(string)
+
+	var provider aws.CredentialsProvider
+	if accessKey := block["access_key"].(string); accessKey != "" {
+		provider = credentials.NewStaticCredentialsProvider(accessKey, block["secret_key"].(string), block["session_token"].(string))
+	} else {
+		provider = defaultCredentials(block["profile"].(string), region)
+	}
+
+	return &sigv4Signer{
+		region:      region,
+		service:     block["service"].(string),
+		now:         time.Now,
+		credentials: provider,
+	}
+}
+
+// defaultCredentials returns the credentials of the default chain of the AWS
+// SDK, for the given profile, or the default one when it is empty. The
+// configuration is only loaded when the credentials are first needed. The
+// region is the one of assumed role and SSO requests, unless the profile
+// sets one.
+func defaultCredentials(profile, region string) aws.CredentialsProvider {
+	var once sync.Once
+	var provider aws.CredentialsProvider
+	var err error
+
+	return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		once.Do(func() {
+			options := []func(*config.LoadOptions) error{config.WithDefaultRegion(region)}
+			if profile != "" {
+				options = append(options, config.WithSharedConfigProfile(profile))
+			}
+
+			var cfg aws.Config
+			if cfg, err = config.LoadDefaultConfig(context.Background(), options...); err == nil {
+				provider = cfg.Credentials
+			}
+		})
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+
+		creds, err := provider.Retrieve(ctx)
+		if err != nil && profile != "" {
+			return creds, fmt.Errorf("profile %s: %w", profile, err)
+		}
+
+		return creds, err
+	})
+}
+
+func (s *sigv4Signer) authenticate(request *http.Request) error {
+	creds, err := s.credentials.Retrieve(request.Context())
+	if err != nil {
+		return &credentialsError{err: err}
+	}
+
+	payloadHash, err := hashPayload(request)
+	if err != nil {
+		return err
+	}
+
+	return s.sign(request, creds, payloadHash, s.now())
+}
+
+// sign sets the X-Amz-Date and Authorization headers of request, along with
+// X-Amz-Security-Token for temporary credentials, and X-Amz-Content-Sha256
+// for S3, which requires it.
+func (s *sigv4Signer) sign(request *http.Request, creds aws.Credentials, payloadHash string, now time.Time) error {
+	request.Header.Del("Authorization")
+	if s.service == "s3" {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// S3 expects the path to be escaped once, and the other services twice.
+	return v4.NewSigner().SignHTTP(request.Context(), creds, request, payloadHash, s.service, s.region, now, func(o *v4.SignerOptions) {
+		o.DisableURIPathEscaping = s.service == "s3"
+	})
+}
+
+// errBodyNotReplayable is returned when a request body has to be signed but
+// can only be read once, by the attempt itself.
+var errBodyNotReplayable = errors.New("the request body cannot be read twice to be signed")
+
+// hashPayload returns the hex encoded SHA-256 hash of the body of request,
+// read from a copy so that the body itself is left for the attempt.
+func hashPayload(request *http.Request) (string, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return sha256Hex(nil), nil
+	}
+
+	if request.GetBody == nil {
+		return "", errBodyNotReplayable
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
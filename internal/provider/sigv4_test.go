@@ -0,0 +1,315 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The vectors of the AWS Signature Version 4 test suite, all signed with the
+// same credentials, scope and time.
+var sigv4TestCredentials = aws.Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+var sigv4TestTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSigV4_TestSuite(t *testing.T) {
+	cases := []struct {
+		name      string
+		method    string
+		url       string
+		headers   map[string]string
+		body      string
+		signature string
+		signed    string
+	}{
+		{
+			name:      "get-vanilla",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+			signed:    "host;x-amz-date",
+		},
+		{
+			name:      "get-vanilla-empty-query-key",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param1=value1",
+			signature: "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb",
+			signed:    "host;x-amz-date",
+		},
+		{
+			name:      "get-vanilla-query-order-key-case",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+			signed:    "host;x-amz-date",
+		},
+		{
+			name:      "post-vanilla",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			signature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+			signed:    "host;x-amz-date",
+		},
+		{
+			name:      "post-x-www-form-urlencoded",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			headers:   map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:      "Param1=value1",
+			signature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+			signed:    "content-type;host;x-amz-date",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+
+			request, err := http.NewRequest(tc.method, tc.url, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tc.headers {
+				request.Header.Set(name, value)
+			}
+			// The requests of the test suite have no Content-Length header.
+			request.ContentLength = 0
+
+			payloadHash, err := hashPayload(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &sigv4Signer{region: "us-east-1", service: "service"}
+			if err := s.sign(request, sigv4TestCredentials, payloadHash, sigv4TestTime); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=" + tc.signed + ", Signature=" + tc.signature
+			if got := request.Header.Get("Authorization"); got != expected {
+				t.Errorf("expected Authorization %q, got %q", expected, got)
+			}
+			if got := request.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("expected X-Amz-Date 20150830T123600Z, got %q", got)
+			}
+		})
+	}
+}
+
+func TestSigV4_RetriesAreResigned(t *testing.T) {
+	var dates, authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dates = append(dates, r.Header.Get("X-Amz-Date"))
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if len(dates) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := schema.TestResourceDataRaw(t, dataSourceScaffolding().Schema, map[string]interface{}{
+		"url": server.URL,
+		"aws_sigv4": []interface{}{map[string]interface{}{
+			"region":        "eu-west-1",
+			"service":       "execute-api",
+			"access_key":    "AKIDEXAMPLE",
+			"secret_key":    "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			"session_token": "session",
+		}},
+		"retry_on_status":  []interface{}{"503"},
+		"initial_interval": 1,
+		"max_interval":     1,
+	})
+
+	opts := testApiClient(t, nil).backoffOptions(d)
+	if len(opts.Auth) != 1 {
+		t.Fatalf("expected the signer alone, got %d authenticators", len(opts.Auth))
+	}
+
+	// Every attempt is signed one second later than the previous one.
+	now := sigv4TestTime
+	opts.Auth[0].(*sigv4Signer).now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/stage/items", strings.NewReader(`{"name": "item"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), http.DefaultClient, request, opts)
+	if errSummary != "" {
+		t.Fatalf("%s: %s", errSummary, errDesc)
+	}
+	drainBody(response)
+
+	if len(dates) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(dates))
+	}
+	for i := 1; i < len(dates); i++ {
+		if dates[i] == dates[i-1] || authorizations[i] == authorizations[i-1] {
+			t.Errorf("expected attempt %d to be signed again, got the date %q and %q", i+1, dates[i], authorizations[i])
+		}
+	}
+	for _, authorization := range authorizations {
+		if !strings.Contains(authorization, "/eu-west-1/execute-api/aws4_request, SignedHeaders=content-length;host;x-amz-date;x-amz-security-token,") {
+			t.Errorf("unexpected Authorization %q", authorization)
+		}
+	}
+}
+
+func TestSigV4_BodyHash(t *testing.T) {
+	sign := func(body string) string {
+		request, err := http.NewRequest(http.MethodPut, "https://example.amazonaws.com/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := &sigv4Signer{region: "us-east-1", service: "s3", now: func() time.Time { return sigv4TestTime }, credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return sigv4TestCredentials, nil
+		})}
+		if err := s.authenticate(request); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, expected := request.Header.Get("X-Amz-Content-Sha256"), sha256Hex([]byte(body)); got != expected {
+			t.Errorf("expected X-Amz-Content-Sha256 %q, got %q", expected, got)
+		}
+
+		return request.Header.Get("Authorization")
+	}
+
+	if sign("one") == sign("two") {
+		t.Error("expected requests with different bodies to have different signatures")
+	}
+}
+
+func TestSigV4_Credentials(t *testing.T) {
+	dir := t.TempDir()
+
+	credentialsFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(credentialsFile, []byte(`[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[ci]
+aws_access_key_id=AKIDCI
+aws_secret_access_key=ci-secret
+aws_session_token=ci-session
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile := filepath.Join(dir, "config")
+	if err := os.WriteFile(configFile, []byte(`# profiles of the config file
+[profile deploy]
+region = eu-west-1
+aws_access_key_id = AKIDDEPLOY
+aws_secret_access_key = deploy-secret
+
+[profile process]
+credential_process = echo '{"Version": 1, "AccessKeyId": "AKIDPROCESS", "SecretAccessKey": "process-secret", "SessionToken": "process-session"}'
+
+[profile broken]
+credential_process = false
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	cases := []struct {
+		name     string
+		env      map[string]string
+		profile  string
+		expected aws.Credentials
+	}{
+		{
+			name:     "default profile",
+			expected: aws.Credentials{AccessKeyID: "AKIDDEFAULT", SecretAccessKey: "default-secret"},
+		},
+		{
+			name:     "environment",
+			env:      map[string]string{"AWS_ACCESS_KEY_ID": "AKIDENV", "AWS_SECRET_ACCESS_KEY": "env-secret", "AWS_SESSION_TOKEN": "env-session"},
+			expected: aws.Credentials{AccessKeyID: "AKIDENV", SecretAccessKey: "env-secret", SessionToken: "env-session"},
+		},
+		{
+			name:     "AWS_PROFILE",
+			env:      map[string]string{"AWS_PROFILE": "ci"},
+			expected: aws.Credentials{AccessKeyID: "AKIDCI", SecretAccessKey: "ci-secret", SessionToken: "ci-session"},
+		},
+		{
+			name:     "profile over environment",
+			env:      map[string]string{"AWS_ACCESS_KEY_ID": "AKIDENV", "AWS_SECRET_ACCESS_KEY": "env-secret"},
+			profile:  "ci",
+			expected: aws.Credentials{AccessKeyID: "AKIDCI", SecretAccessKey: "ci-secret", SessionToken: "ci-session"},
+		},
+		{
+			name:     "config file",
+			profile:  "deploy",
+			expected: aws.Credentials{AccessKeyID: "AKIDDEPLOY", SecretAccessKey: "deploy-secret"},
+		},
+		{
+			name:     "credential_process",
+			profile:  "process",
+			expected: aws.Credentials{AccessKeyID: "AKIDPROCESS", SecretAccessKey: "process-secret", SessionToken: "process-session"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			credentials, err := defaultCredentials(tc.profile, "us-east-1").Retrieve(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credentials.AccessKeyID != tc.expected.AccessKeyID || credentials.SecretAccessKey != tc.expected.SecretAccessKey ||
+				credentials.SessionToken != tc.expected.SessionToken {
+				t.Errorf("expected %+v, got %+v", tc.expected, credentials)
+			}
+		})
+	}
+
+	for _, profile := range []string{"missing", "broken"} {
+		t.Run(profile, func(t *testing.T) {
+			s := &sigv4Signer{region: "us-east-1", service: "execute-api", now: time.Now, credentials: defaultCredentials(profile, "us-east-1")}
+
+			request, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var credErr *credentialsError
+			if err := s.authenticate(request); !errors.As(err, &credErr) || !strings.Contains(err.Error(), profile) {
+				t.Errorf("expected an error naming profile %s, got %v", profile, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/jmespath/go-jmespath"
+)
+
+func successConditionSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "A condition on the response body that must hold for the request to succeed. " +
+			"Requests are retried until every condition holds or the backoff gives up. " +
+			"Each block sets exactly one of `json_path`, `jmespath`, `regex` or `contains`.",
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"json_path": {
+					Description: "A JSONPath expression, such as `$.status`, evaluated against the JSON response body.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"jmespath": {
+					Description: "A JMESPath expression, such as `status == 'UP'`, evaluated against the JSON response body.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+
+				"equals": {
+					Description: "The value that `json_path` or `jmespath` must select, compared as a string. When unset, the selected value " +
+						"must be truthy: not null, false, or an empty string, array or object.",
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+
+				"regex": {
+					Description:  "A regular expression that must match the response body.",
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.StringIsValidRegExp,
+				},
+
+				"contains": {
+					Description: "A string that the response body must contain.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+			},
+		},
+	}
+}
+
+// successCondition is the expanded form of a success_condition block.
+type successCondition struct {
+	JSONPath string
+	JMESPath string
+	Equals   string
+	Regex    string
+	Contains string
+}
+
+func expandSuccessConditions(d *schema.ResourceData) []successCondition {
+	var conditions []successCondition
+
+	for _, raw := range d.Get("success_condition").([]interface{}) {
+		if raw == nil {
+			conditions = append(conditions, successCondition{})
+			continue
+		}
+
+		block := raw.(map[string]interface{})
+		conditions = append(conditions, successCondition{
+			JSONPath: block["json_path"].(string),
+			JMESPath: block["jmespath"].(string),
+			Equals:   block["equals"].(string),
+			Regex:    block["regex"].(string),
+			Contains: block["contains"].(string),
+		})
+	}
+
+	return conditions
+}
+
+func (c successCondition) validate() error {
+	set := 0
+	for _, v := range []string{c.JSONPath, c.JMESPath, c.Regex, c.Contains} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return fmt.Errorf("exactly one of json_path, jmespath, regex or contains must be set")
+	}
+
+	if c.Equals != "" && c.JSONPath == "" && c.JMESPath == "" {
+		return fmt.Errorf("equals can only be used with json_path or jmespath")
+	}
+
+	if c.JMESPath != "" {
+		if _, err := jmespath.Compile(c.JMESPath); err != nil {
+			return fmt.Errorf("invalid JMESPath expression %q: %w", c.JMESPath, err)
+		}
+	}
+
+	if c.Regex != "" {
+		if _, err := regexp.Compile(c.Regex); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %w", c.Regex, err)
+		}
+	}
+
+	return nil
+}
+
+func (c successCondition) String() string {
+	var s string
+	switch {
+	case c.JSONPath != "":
+		s = fmt.Sprintf("json_path %s", c.JSONPath)
+	case c.JMESPath != "":
+		s = fmt.Sprintf("jmespath %s", c.JMESPath)
+	case c.Regex != "":
+		return fmt.Sprintf("regex /%s/", c.Regex)
+	default:
+		return fmt.Sprintf("contains %q", c.Contains)
+	}
+
+	if c.Equals != "" {
+		s += fmt.Sprintf(" equals %q", c.Equals)
+	}
+
+	return s
+}
+
+// check returns an error describing why the condition does not hold for body.
+func (c successCondition) check(body []byte) error {
+	switch {
+	case c.Regex != "":
+		if !regexp.MustCompile(c.Regex).Match(body) {
+			return fmt.Errorf("response body does not match")
+		}
+		return nil
+	case c.Contains != "":
+		if !strings.Contains(string(body), c.Contains) {
+			return fmt.Errorf("response body does not contain the expected string")
+		}
+		return nil
+	}
+
+	var value interface{}
+	if c.JSONPath != "" {
+		document, err := decodeJSON(body)
+		if err != nil {
+			return fmt.Errorf("response body is not valid JSON: %w", err)
+		}
+
+		value, err = jsonPathLookup(document, c.JSONPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		var document interface{}
+		if err := json.Unmarshal(body, &document); err != nil {
+			return fmt.Errorf("response body is not valid JSON: %w", err)
+		}
+
+		var err error
+		value, err = jmespath.Search(c.JMESPath, document)
+		if err != nil {
+			return err
+		}
+	}
+
+	got, err := jsonValueString(value)
+	if err != nil {
+		return err
+	}
+
+	if c.Equals != "" {
+		if got != c.Equals {
+			return fmt.Errorf("got %q", got)
+		}
+		return nil
+	}
+
+	if !isTruthy(value) {
+		return fmt.Errorf("got %q", got)
+	}
+
+	return nil
+}
+
+// checkSuccessConditions returns an error naming the first condition that
+// does not hold for body.
+func checkSuccessConditions(conditions []successCondition, body []byte) error {
+	for i, c := range conditions {
+		if err := c.check(body); err != nil {
+			return fmt.Errorf("success_condition %d (%s) not met: %s", i, c, err)
+		}
+	}
+
+	return nil
+}
+
+// isTruthy follows the JMESPath definition of truth, where null, false and
+// empty strings, arrays and objects are false.
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuccessConditionCheck(t *testing.T) {
+	body := []byte(`{"status": "UP", "components": {"db": {"status": "DOWN"}}, "replicas": 3, "ready": false}`)
+
+	testCases := []struct {
+		name      string
+		condition successCondition
+		success   bool
+	}{
+		{name: "json_path equals", condition: successCondition{JSONPath: "$.status", Equals: "UP"}, success: true},
+		{name: "json_path not equal", condition: successCondition{JSONPath: "$.components.db.status", Equals: "UP"}},
+		{name: "json_path number", condition: successCondition{JSONPath: "$.replicas", Equals: "3"}, success: true},
+		{name: "json_path truthy", condition: successCondition{JSONPath: "$.status"}, success: true},
+		{name: "json_path falsy", condition: successCondition{JSONPath: "$.ready"}},
+		{name: "json_path missing", condition: successCondition{JSONPath: "$.missing"}},
+		{name: "jmespath expression", condition: successCondition{JMESPath: "status == 'UP'"}, success: true},
+		{name: "jmespath expression false", condition: successCondition{JMESPath: "components.db.status == 'UP'"}},
+		{name: "jmespath equals", condition: successCondition{JMESPath: "replicas", Equals: "3"}, success: true},
+		{name: "regex", condition: successCondition{Regex: `"status":\s*"UP"`}, success: true},
+		{name: "regex no match", condition: successCondition{Regex: `ready: true`}},
+		{name: "contains", condition: successCondition{Contains: `"UP"`}, success: true},
+		{name: "contains no match", condition: successCondition{Contains: "healthy"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.condition.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			err := tc.condition.check(body)
+			if tc.success && err != nil {
+				t.Errorf("expected the condition to hold, got %s", err)
+			}
+			if !tc.success && err == nil {
+				t.Error("expected the condition not to hold")
+			}
+		})
+	}
+}
+
+func TestSuccessConditionValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		condition successCondition
+	}{
+		{name: "empty", condition: successCondition{}},
+		{name: "several", condition: successCondition{Regex: "a", Contains: "a"}},
+		{name: "equals without path", condition: successCondition{Contains: "a", Equals: "a"}},
+		{name: "invalid jmespath", condition: successCondition{JMESPath: "status =="}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.condition.validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCheckSuccessConditions(t *testing.T) {
+	conditions := []successCondition{
+		{Contains: "status"},
+		{JSONPath: "$.status", Equals: "UP"},
+	}
+
+	err := checkSuccessConditions(conditions, []byte(`{"status": "DOWN"}`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	expected := `success_condition 1 (json_path $.status equals "UP") not met: got "DOWN"`
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+}
@@ -0,0 +1,481 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// transportSchema returns the attributes that configure the HTTP client.
+// They are set on the provider and can be overridden by every data source
+// and resource.
+func transportSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"attempt_timeout": {
+			Description:  "The maximum time in milliseconds a single attempt may take, including reading the response body. Unlimited by default.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+
+		"connect_timeout": {
+			Description:  "The maximum time in milliseconds to establish a TCP connection. Defaults to 30 seconds.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+
+		"tls_handshake_timeout": {
+			Description:  "The maximum time in milliseconds for the TLS handshake. Defaults to 10 seconds.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+
+		"response_header_timeout": {
+			Description:  "The maximum time in milliseconds to wait for the response headers once the request is sent. Unlimited by default.",
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+
+		"insecure_skip_verify": {
+			Description: "Disables the verification of the server certificate chain and host name. Only use this for testing.",
+			Type:        schema.TypeBool,
+			Optional:    true,
+		},
+
+		"ca_cert_pem": {
+			Description: "PEM encoded CA certificates trusted to sign server certificates, in addition to the system trust store.",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+
+		"ca_cert_file": {
+			Description: "Path to a file of PEM encoded CA certificates trusted to sign server certificates, in addition to the system trust store.",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+
+		"client_cert_pem": {
+			Description:  "PEM encoded client certificate presented to servers that require mutual TLS.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			RequiredWith: []string{"client_key_pem"},
+		},
+
+		"client_key_pem": {
+			Description:  "PEM encoded private key of `client_cert_pem`.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			Sensitive:    true,
+			RequiredWith: []string{"client_cert_pem"},
+		},
+
+		"tls_server_name": {
+			Description: "The server name used to verify the server certificate and sent through SNI, when it differs from the host of the URL.",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+
+		"pinned_cert_sha256": {
+			Description: "SHA-256 fingerprints of the leaf server certificates to accept, hex (optionally colon separated) or base64 encoded. " +
+				"When pins are set, a server whose certificate matches none of `pinned_cert_sha256` and `pinned_spki_sha256` is rejected without retrying.",
+			Type:     schema.TypeSet,
+			Elem:     &schema.Schema{Type: schema.TypeString, ValidateFunc: validatePin},
+			Optional: true,
+		},
+
+		"pinned_spki_sha256": {
+			Description: "SHA-256 fingerprints of the subject public key info of the leaf server certificates to accept, " +
+				"hex (optionally colon separated) or base64 encoded. Unlike certificate pins, they survive a renewal that keeps the key.",
+			Type:     schema.TypeSet,
+			Elem:     &schema.Schema{Type: schema.TypeString, ValidateFunc: validatePin},
+			Optional: true,
+		},
+
+		"min_tls_version": {
+			Description:  "The minimum TLS version to accept. One of `1.0`, `1.1`, `1.2` or `1.3`. Defaults to `1.2`.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{"1.0", "1.1", "1.2", "1.3"}, false),
+		},
+
+		"proxy_url": {
+			Description: "The proxy requests go through, with an `http`, `https`, `socks5` or `socks5h` scheme. " +
+				"Defaults to the `HTTP_PROXY` and `HTTPS_PROXY` environment variables.",
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "socks5", "socks5h"}),
+		},
+
+		"proxy_headers": {
+			Description: "Headers sent to HTTP proxies, with `CONNECT` requests and with proxied `http` requests.",
+			Type:        schema.TypeMap,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Optional:    true,
+		},
+
+		"no_proxy": {
+			Description: "Hosts, domains (`.example.com`), IP addresses and CIDR ranges reached directly rather than through the proxy, " +
+				"or `*` for all of them. Replaces the `NO_PROXY` environment variable.",
+			Type:     schema.TypeList,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+			Optional: true,
+		},
+
+		"proxy_auth": {
+			Description: "The credentials sent to the proxy.",
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"username": {
+						Description: "The user name.",
+						Type:        schema.TypeString,
+						Required:    true,
+					},
+
+					"password": {
+						Description: "The password.",
+						Type:        schema.TypeString,
+						Required:    true,
+						Sensitive:   true,
+					},
+				},
+			},
+		},
+
+		"proxy_ca_cert_pem": {
+			Description: "PEM encoded CA certificates trusted to sign the certificate of an `https` proxy, in addition to the system trust store.",
+			Type:        schema.TypeString,
+			Optional:    true,
+		},
+
+		"resolve": {
+			Description: "IP addresses to connect to instead of resolving hosts, keyed by `host:port`, as with `curl --resolve`. " +
+				"The Host header and the server name verified through TLS remain the host of the URL.",
+			Type:     schema.TypeMap,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+			Optional: true,
+		},
+
+		"connect_to": {
+			Description: "Rules of the form `HOST1:PORT1:HOST2:PORT2` connecting to `HOST2:PORT2` instead of `HOST1:PORT1`, as with " +
+				"`curl --connect-to`. An empty field matches any host or port, or keeps it. The first matching rule applies, " +
+				"and the Host header and the server name verified through TLS remain the host of the URL.",
+			Type:     schema.TypeList,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+			Optional: true,
+		},
+
+		"unix_socket": {
+			Description: "The path of a Unix domain socket every connection is made to, instead of the host of the URL. " +
+				"The host is still sent in the `Host` header, and proxies are not used.",
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+	}
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// transportConfig holds the settings of the HTTP client. A zero value means
+// that the setting is not set.
+type transportConfig struct {
+	AttemptTimeout        time.Duration
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	InsecureSkipVerify    bool
+	CACertPEM             string
+	CACertFile            string
+	ClientCertPEM         string
+	ClientKeyPEM          string
+	TLSServerName         string
+	MinTLSVersion         string
+	PinnedCertSHA256      []string
+	PinnedSPKISHA256      []string
+	ProxyURL              string
+	ProxyHeaders          map[string]string
+	NoProxy               []string
+	ProxyUsername         string
+	ProxyPassword         string
+	ProxyCACertPEM        string
+	UnixSocket            string
+	Resolve               map[string]string
+	ConnectTo             []string
+}
+
+// key identifies the clients built from c.
+func (c transportConfig) key() string {
+	encoded, _ := json.Marshal(c)
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func expandTransportConfig(d *schema.ResourceData) transportConfig {
+	c := transportConfig{
+		AttemptTimeout:        time.Duration(d.Get("attempt_timeout").(int)) * time.Millisecond,
+		ConnectTimeout:        time.Duration(d.Get("connect_timeout").(int)) * time.Millisecond,
+		TLSHandshakeTimeout:   time.Duration(d.Get("tls_handshake_timeout").(int)) * time.Millisecond,
+		ResponseHeaderTimeout: time.Duration(d.Get("response_header_timeout").(int)) * time.Millisecond,
+		InsecureSkipVerify:    d.Get("insecure_skip_verify").(bool),
+		CACertPEM:             d.Get("ca_cert_pem").(string),
+		CACertFile:            d.Get("ca_cert_file").(string),
+		ClientCertPEM:         d.Get("client_cert_pem").(string),
+		ClientKeyPEM:          d.Get("client_key_pem").(string),
+		TLSServerName:         d.Get("tls_server_name").(string),
+		MinTLSVersion:         d.Get("min_tls_version").(string),
+		PinnedCertSHA256:      expandPins(d.Get("pinned_cert_sha256").(*schema.Set).List()),
+		PinnedSPKISHA256:      expandPins(d.Get("pinned_spki_sha256").(*schema.Set).List()),
+		ProxyURL:              d.Get("proxy_url").(string),
+		ProxyCACertPEM:        d.Get("proxy_ca_cert_pem").(string),
+		UnixSocket:            d.Get("unix_socket").(string),
+	}
+
+	// Empty lists and maps are left nil, so that isZero tells whether
+	// anything is set.
+	if noProxy := d.Get("no_proxy").([]interface{}); len(noProxy) > 0 {
+		c.NoProxy = expandStringList(noProxy)
+	}
+
+	if connectTo := d.Get("connect_to").([]interface{}); len(connectTo) > 0 {
+		c.ConnectTo = expandStringList(connectTo)
+	}
+
+	for address, ip := range d.Get("resolve").(map[string]interface{}) {
+		if c.Resolve == nil {
+			c.Resolve = map[string]string{}
+		}
+		c.Resolve[address] = ip.(string)
+	}
+
+	for name, value := range d.Get("proxy_headers").(map[string]interface{}) {
+		if c.ProxyHeaders == nil {
+			c.ProxyHeaders = map[string]string{}
+		}
+		c.ProxyHeaders[name] = value.(string)
+	}
+
+	if auth := firstBlock(d.Get("proxy_auth")); auth != nil {
+		c.ProxyUsername = auth["username"].(string)
+		c.ProxyPassword = auth["password"].(string)
+	}
+
+	return c
+}
+
+func (c transportConfig) isZero() bool {
+	return reflect.DeepEqual(c, transportConfig{})
+}
+
+// merge returns the settings of c overridden by the settings of override
+// that are set.
+func (c transportConfig) merge(override transportConfig) transportConfig {
+	merged := c
+
+	if override.AttemptTimeout != 0 {
+		merged.AttemptTimeout = override.AttemptTimeout
+	}
+
+	if override.ConnectTimeout != 0 {
+		merged.ConnectTimeout = override.ConnectTimeout
+	}
+
+	if override.TLSHandshakeTimeout != 0 {
+		merged.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+
+	if override.ResponseHeaderTimeout != 0 {
+		merged.ResponseHeaderTimeout = override.ResponseHeaderTimeout
+	}
+
+	if override.InsecureSkipVerify {
+		merged.InsecureSkipVerify = true
+	}
+
+	if override.CACertPEM != "" {
+		merged.CACertPEM = override.CACertPEM
+	}
+
+	if override.CACertFile != "" {
+		merged.CACertFile = override.CACertFile
+	}
+
+	// The certificate and its key only make sense together.
+	if override.ClientCertPEM != "" {
+		merged.ClientCertPEM = override.ClientCertPEM
+		merged.ClientKeyPEM = override.ClientKeyPEM
+	}
+
+	if override.TLSServerName != "" {
+		merged.TLSServerName = override.TLSServerName
+	}
+
+	if override.MinTLSVersion != "" {
+		merged.MinTLSVersion = override.MinTLSVersion
+	}
+
+	// Pins of either kind replace all the pins of the provider, as keeping
+	// some of them would let through servers the override meant to exclude.
+	if len(override.PinnedCertSHA256) > 0 || len(override.PinnedSPKISHA256) > 0 {
+		merged.PinnedCertSHA256 = override.PinnedCertSHA256
+		merged.PinnedSPKISHA256 = override.PinnedSPKISHA256
+	}
+
+	if override.ProxyURL != "" {
+		merged.ProxyURL = override.ProxyURL
+	}
+
+	if len(override.ProxyHeaders) > 0 {
+		merged.ProxyHeaders = override.ProxyHeaders
+	}
+
+	if len(override.NoProxy) > 0 {
+		merged.NoProxy = override.NoProxy
+	}
+
+	if override.ProxyUsername != "" {
+		merged.ProxyUsername = override.ProxyUsername
+		merged.ProxyPassword = override.ProxyPassword
+	}
+
+	if override.ProxyCACertPEM != "" {
+		merged.ProxyCACertPEM = override.ProxyCACertPEM
+	}
+
+	if override.UnixSocket != "" {
+		merged.UnixSocket = override.UnixSocket
+	}
+
+	if len(override.Resolve) > 0 {
+		merged.Resolve = override.Resolve
+	}
+
+	if len(override.ConnectTo) > 0 {
+		merged.ConnectTo = override.ConnectTo
+	}
+
+	return merged
+}
+
+// newHTTPClient builds an HTTP client with its own transport from c.
+func newHTTPClient(c transportConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if c.ConnectTimeout != 0 {
+		dialer.Timeout = c.ConnectTimeout
+	}
+
+	overrides, err := c.addressOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := c.proxy()
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy.forRequest
+	transport.DialContext = proxy.dialContext(overrides.dialContext(dialer.DialContext))
+	transport.ProxyConnectHeader = proxy.headers
+
+	if c.UnixSocket != "" {
+		transport.Proxy = nil
+		transport.DialContext = dialUnixSocket(dialer, c.UnixSocket)
+	}
+
+	if c.TLSHandshakeTimeout != 0 {
+		transport.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	}
+	transport.ResponseHeaderTimeout = c.ResponseHeaderTimeout
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// Unix URLs get transports of their own, cloned from this one once it
+	// is fully configured.
+	transport.RegisterProtocol("unix", &unixSocketTransport{base: transport.Clone(), dialer: dialer})
+
+	return &http.Client{
+		Transport: proxy.wrap(transport),
+		Timeout:   c.AttemptTimeout,
+	}, nil
+}
+
+// tlsConfig builds the TLS configuration of the transport from c.
+func (c transportConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		//nolint:gosec
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         c.TLSServerName,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if c.MinTLSVersion != "" {
+		tlsConfig.MinVersion = tlsVersions[c.MinTLSVersion]
+	}
+
+	if c.CACertPEM != "" || c.CACertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if c.CACertPEM != "" && !pool.AppendCertsFromPEM([]byte(c.CACertPEM)) {
+			return nil, fmt.Errorf("ca_cert_pem does not contain any valid PEM encoded certificate")
+		}
+
+		if c.CACertFile != "" {
+			pem, err := ioutil.ReadFile(c.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading ca_cert_file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ca_cert_file %s does not contain any valid PEM encoded certificate", c.CACertFile)
+			}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.ClientCertPEM != "" {
+		certificate, err := tls.X509KeyPair([]byte(c.ClientCertPEM), []byte(c.ClientKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("error loading client_cert_pem and client_key_pem: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if len(c.PinnedCertSHA256) > 0 || len(c.PinnedSPKISHA256) > 0 {
+		tlsConfig.VerifyPeerCertificate = verifyPins(c.PinnedCertSHA256, c.PinnedSPKISHA256)
+	}
+
+	return tlsConfig, nil
+}
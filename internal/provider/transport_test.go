@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerCertPEM returns the PEM encoded certificate of a TLS test server.
+func testServerCertPEM(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+// testClientCertificate generates a self-signed client certificate and
+// returns it along with its key, both PEM encoded.
+func testClientCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "terraform"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func testGet(t *testing.T, c transportConfig, url string) error {
+	t.Helper()
+
+	client, err := newHTTPClient(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	drainBody(response)
+
+	return nil
+}
+
+func TestTransportConfig_CACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if err := testGet(t, transportConfig{}, server.URL); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected a certificate error without a CA, got %v", err)
+	}
+
+	if err := testGet(t, transportConfig{CACertPEM: testServerCertPEM(server)}, server.URL); err != nil {
+		t.Errorf("expected ca_cert_pem to be trusted, got %s", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte(testServerCertPEM(server)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testGet(t, transportConfig{CACertFile: caFile}, server.URL); err != nil {
+		t.Errorf("expected ca_cert_file to be trusted, got %s", err)
+	}
+
+	if err := testGet(t, transportConfig{InsecureSkipVerify: true}, server.URL); err != nil {
+		t.Errorf("expected insecure_skip_verify to skip verification, got %s", err)
+	}
+}
+
+func TestTransportConfig_ServerName(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The certificate of httptest servers is valid for example.com.
+	c := transportConfig{CACertPEM: testServerCertPEM(server), TLSServerName: "example.com"}
+	if err := testGet(t, c, server.URL); err != nil {
+		t.Errorf("expected tls_server_name to be verified, got %s", err)
+	}
+
+	c.TLSServerName = "other.example.org"
+	if err := testGet(t, c, server.URL); err == nil {
+		t.Error("expected an error for a server name that is not in the certificate")
+	}
+}
+
+func TestTransportConfig_ClientCert(t *testing.T) {
+	certPEM, keyPEM := testClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM([]byte(certPEM))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	c := transportConfig{CACertPEM: testServerCertPEM(server)}
+	if err := testGet(t, c, server.URL); err == nil {
+		t.Error("expected an error without a client certificate")
+	}
+
+	c.ClientCertPEM = certPEM
+	c.ClientKeyPEM = keyPEM
+	if err := testGet(t, c, server.URL); err != nil {
+		t.Errorf("expected the client certificate to be accepted, got %s", err)
+	}
+}
+
+func TestTransportConfig_MinTLSVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	c := transportConfig{CACertPEM: testServerCertPEM(server), MinTLSVersion: "1.2"}
+	if err := testGet(t, c, server.URL); err != nil {
+		t.Errorf("expected TLS 1.2 to be accepted, got %s", err)
+	}
+
+	c.MinTLSVersion = "1.3"
+	if err := testGet(t, c, server.URL); err == nil {
+		t.Error("expected TLS 1.2 to be rejected")
+	}
+}
+
+func TestTransportConfig_InvalidPEM(t *testing.T) {
+	if _, err := newHTTPClient(transportConfig{CACertPEM: "not a certificate"}); err == nil {
+		t.Error("expected an error for an invalid ca_cert_pem")
+	}
+
+	if _, err := newHTTPClient(transportConfig{ClientCertPEM: "not a certificate", ClientKeyPEM: "not a key"}); err == nil {
+		t.Error("expected an error for an invalid client certificate")
+	}
+}
+
+func TestTransportConfig_TimedOutAttemptIsRetried(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config transportConfig
+	}{
+		{name: "attempt_timeout", config: transportConfig{AttemptTimeout: 200 * time.Millisecond}},
+		{name: "response_header_timeout", config: transportConfig{ResponseHeaderTimeout: 200 * time.Millisecond}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts == 1 {
+					// Accept the request and never reply within the timeout.
+					select {
+					case <-r.Context().Done():
+					case <-time.After(5 * time.Second):
+					}
+					return
+				}
+				_, _ = w.Write([]byte("ready"))
+			}))
+			defer server.Close()
+
+			client, err := newHTTPClient(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), client, request, backoffOptions{
+				InitialInterval: 10,
+				MaxElapsedTime:  4,
+			})
+			if errSummary != "" {
+				t.Fatalf("%s: %s", errSummary, errDesc)
+			}
+			drainBody(response)
+
+			if attempts != 2 {
+				t.Errorf("expected the timed out attempt to be retried once, got %d attempts", attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// unixSocketTransport sends the requests of unix URLs, such as
+// unix:///var/run/docker.sock:/v1.41/_ping, as http requests to the path
+// after the colon, over the socket before it.
+type unixSocketTransport struct {
+	base   *http.Transport
+	dialer *net.Dialer
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func (t *unixSocketTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	socket, target, err := unixSocketTarget(request.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := request.Clone(request.Context())
+	attempt.URL = target
+	attempt.Host = target.Host
+
+	response, err := t.transport(socket).RoundTrip(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Redirects are resolved against the URL of the original request.
+	response.Request = request
+
+	return response, nil
+}
+
+// transport returns the transport connecting to socket, which keeps its own
+// idle connections.
+func (t *unixSocketTransport) transport(socket string) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if transport, ok := t.transports[socket]; ok {
+		return transport
+	}
+
+	if t.transports == nil {
+		t.transports = map[string]*http.Transport{}
+	}
+
+	transport := t.base.Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialUnixSocket(t.dialer, socket)
+	t.transports[socket] = transport
+
+	return transport
+}
+
+// unixSocketTarget splits a unix URL into the path of its socket and the http
+// URL to request over it.
+func unixSocketTarget(u *url.URL) (string, *url.URL, error) {
+	socket, path, _ := strings.Cut(u.EscapedPath(), ":")
+	if socket == "" || socket == "/" {
+		return "", nil, fmt.Errorf("%s does not name a socket, expected unix:///path/to.sock:/http/path", redactURL(u))
+	}
+
+	socket, err := url.PathUnescape(socket)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	target, err := url.Parse("http://localhost" + path)
+	if err != nil {
+		return "", nil, err
+	}
+	target.RawQuery = u.RawQuery
+
+	return socket, target, nil
+}
+
+// dialUnixSocket returns a DialContext function that connects to socket,
+// whatever the address it is asked for.
+func dialUnixSocket(dialer *net.Dialer, socket string) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
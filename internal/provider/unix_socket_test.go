@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testUnixSocketServer serves handler on a Unix domain socket and returns the
+// path of the socket.
+func testUnixSocketServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { server.Close() })
+
+	return socket
+}
+
+func TestUnixSocket_URL(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+
+	socket := testUnixSocketServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, r.Host+" "+r.URL.String())
+		if len(requests) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+
+	client, err := newHTTPClient(transportConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "unix://"+socket+":/v1.41/containers/json?all=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, errSummary, errDesc := makeExponentialBackoffRequest(context.Background(), client, request, backoffOptions{
+		InitialInterval: 1,
+		MaxElapsedTime:  5,
+		MaxInterval:     1,
+		RetryOnStatus:   []string{"503"},
+	})
+	if errSummary != "" {
+		t.Fatalf("%s: %s", errSummary, errDesc)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	if string(body) != "OK" {
+		t.Errorf("expected the response of the socket, got %q", body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(requests) != 2 {
+		t.Fatalf("expected the request to be retried over the socket, got %v", requests)
+	}
+	for _, r := range requests {
+		if r != "localhost /v1.41/containers/json?all=1" {
+			t.Errorf("expected a request for /v1.41/containers/json?all=1, got %q", r)
+		}
+	}
+}
+
+func TestUnixSocket_Attribute(t *testing.T) {
+	hosts := make(chan string, 1)
+	socket := testUnixSocketServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+
+	// The host of the URL does not resolve, only the socket is dialed.
+	if err := testGet(t, transportConfig{UnixSocket: socket}, "http://admin.internal:8080/health"); err != nil {
+		t.Fatal(err)
+	}
+
+	if host := <-hosts; host != "admin.internal:8080" {
+		t.Errorf("expected the Host header of the URL, got %q", host)
+	}
+}
+
+func TestUnixSocketTarget(t *testing.T) {
+	testCases := []struct {
+		url    string
+		socket string
+		target string
+	}{
+		{"unix:///var/run/docker.sock:/_ping", "/var/run/docker.sock", "http://localhost/_ping"},
+		{"unix:///var/run/docker.sock", "/var/run/docker.sock", "http://localhost/"},
+		{"unix:///run/app%20admin.sock:/api/items?page=2", "/run/app admin.sock", "http://localhost/api/items?page=2"},
+	}
+
+	for _, tc := range testCases {
+		request, err := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		socket, target, err := unixSocketTarget(request.URL)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.url, err)
+		}
+		if socket != tc.socket || target.String() != tc.target {
+			t.Errorf("%s: expected %s and %s, got %s and %s", tc.url, tc.socket, tc.target, socket, target)
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "unix:///:/path", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := unixSocketTarget(request.URL); err == nil {
+		t.Error("expected an error for a URL without a socket")
+	}
+}